@@ -75,7 +75,10 @@ class Config(AttrDict):
     @property
     def description(self):
         total = self.n + self.n * self.rep
-        return '%d %s' % (total, MODE_NAMES[self.m])
+        result = '%d %s' % (total, MODE_NAMES[self.m])
+        if self.a == 0:
+            result += ' with optimized opacity'
+        return result
 
 def clamp(x, lo, hi):
     if x < lo:
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fogleman/primitive/primitive"
+	"github.com/nfnt/resize"
+)
+
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".webp": true, ".tif": true, ".tiff": true, ".bmp": true,
+}
+
+// runLQIP implements the lqip subcommand, which makes small blurred SVG
+// placeholders for web pages and prints them as data URIs. Given a
+// directory, it writes a JSON object mapping each image's path relative to
+// the directory to its placeholder.
+func runLQIP(args []string) {
+	flags := flag.NewFlagSet("lqip", flag.ExitOnError)
+	input := flags.String("i", "", "input image or directory")
+	output := flags.String("o", "-", "output path for the data URI or JSON map")
+	count := flags.Int("n", 20, "number of primitives")
+	mode := flags.Int("m", 1, "0=combo 1=triangle 2=rect 3=ellipse 4=circle 5=rotatedrect 6=beziers 7=rotatedellipse 8=polygon")
+	alpha := flags.Int("a", 128, "alpha value")
+	inputSize := flags.Int("r", 64, "resize input images to this size")
+	blur := flags.Float64("blur", 2, "blur standard deviation in placeholder pixels (0 = off)")
+	seed := flags.Int64("seed", 0, "random seed for repeatable output (0 = random)")
+	workers := flags.Int("j", 0, "number of parallel workers (default uses all cores)")
+	verbose := flags.Bool("v", false, "verbose")
+	flags.Parse(args)
+
+	ok := true
+	if *input == "" {
+		ok = errorMessage("ERROR: input argument required")
+	}
+	if *count < 1 {
+		ok = errorMessage("ERROR: number argument must be > 0")
+	}
+	if *blur < 0 {
+		ok = errorMessage("ERROR: blur argument must be >= 0")
+	}
+	if !ok {
+		fmt.Println("Usage: primitive lqip [OPTIONS] -i input [-o output]")
+		flags.PrintDefaults()
+		os.Exit(1)
+	}
+	if *verbose {
+		primitive.LogLevel = 1
+	}
+	if *seed == 0 {
+		rand.Seed(time.Now().UTC().UnixNano())
+	} else {
+		rand.Seed(*seed)
+	}
+	if *workers < 1 {
+		*workers = runtime.NumCPU()
+	}
+
+	placeholder := func(path string) (string, error) {
+		primitive.Log(1, "reading %s\n", path)
+		im, err := primitive.LoadImage(path)
+		if err != nil {
+			return "", err
+		}
+		size := uint(*inputSize)
+		im = resize.Thumbnail(size, size, im, resize.Bilinear)
+		bg := primitive.MakeColor(primitive.AverageImageColor(im))
+		// the viewBox scales the placeholder, so it is kept at input size
+		// where the coordinates are short integers
+		s := im.Bounds().Size()
+		model := primitive.NewModel(im, bg, maxInt(s.X, s.Y), *workers)
+		if *seed != 0 {
+			model.Seed(*seed)
+		}
+		// placeholders are small and blurred, so a short search will do
+		for i := 0; i < *count; i++ {
+			model.StepSearch(primitive.ShapeType(*mode), *alpha, 100, 20, 4)
+		}
+		svg := model.CompactSVG(primitive.CompactSVG{Precision: 0, ViewBox: true, Blur: *blur})
+		uri := primitive.SVGDataURI(svg)
+		primitive.Log(1, "%s: score=%.6f, %d bytes\n", path, model.Score, len(uri))
+		return uri, nil
+	}
+
+	info, err := os.Stat(*input)
+	check(err)
+	var result []byte
+	failed := false
+	if !info.IsDir() {
+		uri, err := placeholder(*input)
+		check(err)
+		result = []byte(uri + "\n")
+	} else {
+		var paths []string
+		check(filepath.Walk(*input, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// skip unreadable files and directories but keep going
+				log.Print(err)
+				failed = true
+				return nil
+			}
+			if !info.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(path))] {
+				paths = append(paths, path)
+			}
+			return nil
+		}))
+		sort.Strings(paths)
+		placeholders := make(map[string]string)
+		for _, path := range paths {
+			uri, err := placeholder(path)
+			if err != nil {
+				log.Printf("%s: %v", path, err)
+				failed = true
+				continue
+			}
+			rel, err := filepath.Rel(*input, path)
+			check(err)
+			placeholders[filepath.ToSlash(rel)] = uri
+		}
+		result, err = json.MarshalIndent(placeholders, "", "  ")
+		check(err)
+		result = append(result, '\n')
+	}
+	if *output == "-" {
+		os.Stdout.Write(result)
+	} else {
+		check(ioutil.WriteFile(*output, result, 0644))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"image"
 	"log"
 	"math/rand"
 	"os"
@@ -18,15 +19,49 @@ import (
 
 var (
 	Input      string
+	From       string
+	Canvas     string
+	CanvasHref string
 	Outputs    flagArray
 	Background string
+	Blend      string
+	Palette    string
+	Colors     string
+	ColorMode  string
+	Gray       bool
+	Duotone    string
 	Configs    shapeConfigArray
 	Alpha      int
 	InputSize  int
 	OutputSize int
 	Mode       int
 	Workers    int
+	Pyramid    int
+	Render     string
+	Tiles      int
+	Overlap    int
+	Speculate  int
+	Seed       int64
+	NoExif     bool
+	FPS        float64
+	Duration   float64
+	Fade       float64
+	Easing     string
+	Hold       float64
+	Pipe       string
+	SVGAnim    string
+	Pop        bool
+	Batch      float64
+	Compact    bool
+	Precision  int
+	Merge      bool
+	SVGMeta    bool
+	SVGSort    bool
+	PlotSize   float64
+	Hatch      float64
 	Nth        int
+	Track      string
+	Age        int
 	Repeat     int
 	V, VV      bool
 )
@@ -64,14 +99,48 @@ func (i *shapeConfigArray) Set(value string) error {
 func init() {
 	flag.StringVar(&Input, "i", "", "input image path")
 	flag.Var(&Outputs, "o", "output image path")
+	flag.StringVar(&From, "from", "", "continue from a previous svg or json result")
+	flag.StringVar(&Canvas, "canvas", "", "starting canvas: image path, or blur[:N] for the input scaled down by N (default 16) and back up")
+	flag.StringVar(&CanvasHref, "canvashref", "", "reference the canvas from svg, html and css output by this url instead of embedding it")
 	flag.Var(&Configs, "n", "number of primitives")
-	flag.StringVar(&Background, "bg", "", "background color (hex)")
+	flag.StringVar(&Background, "bg", "", "background: hex color, avg, median, dominant, optimal, gradient, or none for transparent")
+	flag.StringVar(&Blend, "blend", "normal", "blend mode: normal, multiply, screen, add, difference")
+	flag.StringVar(&Palette, "palette", "", "restrict colors to a palette (hex list, .gpl/.ase file, or N or kmeans:N to extract N colors)")
+	flag.StringVar(&Colors, "colors", "", "image to take shape colors from instead of the input")
+	flag.StringVar(&ColorMode, "colormode", "palette", "colors image mode: palette (N colors extracted from it, N from -palette, default 16) or local (its color under each shape)")
+	flag.BoolVar(&Gray, "gray", false, "operate on luminance only and output grayscale")
+	flag.StringVar(&Duotone, "duotone", "", "grayscale with output mapped between two hex colors (dark,light)")
 	flag.IntVar(&Alpha, "a", 128, "alpha value")
 	flag.IntVar(&InputSize, "r", 256, "resize large input images to this size")
 	flag.IntVar(&OutputSize, "s", 1024, "output image size")
 	flag.IntVar(&Mode, "m", 1, "0=combo 1=triangle 2=rect 3=ellipse 4=circle 5=rotatedrect 6=beziers 7=rotatedellipse 8=polygon")
 	flag.IntVar(&Workers, "j", 0, "number of parallel workers (default uses all cores)")
+	flag.IntVar(&Pyramid, "pyramid", 0, "search for shapes at 1/N resolution and refine the best at full resolution (0 = off)")
+	flag.StringVar(&Render, "render", "native", "output renderer: native (matches the scored image) or gg (antialiased)")
+	flag.IntVar(&Tiles, "tiles", 0, "split large images into an NxN grid of tiles optimized in parallel (0 = off)")
+	flag.IntVar(&Overlap, "overlap", 16, "tile overlap in input pixels")
+	flag.IntVar(&Speculate, "speculate", 1, "place up to N non-overlapping shapes per step, one from each worker")
+	flag.Int64Var(&Seed, "seed", 0, "random seed for repeatable output (0 = random)")
+	flag.BoolVar(&NoExif, "noexif", false, "ignore the EXIF orientation of jpeg input")
+	flag.Float64Var(&FPS, "fps", 30, "frame rate of piped animations")
+	flag.Float64Var(&Duration, "duration", 5, "seconds over which shapes appear in animated output")
+	flag.Float64Var(&Fade, "fade", 0.5, "seconds each shape takes to fade in in piped and svg animations")
+	flag.StringVar(&Easing, "easing", "out", "shape fade easing: linear, in, out, inout")
+	flag.Float64Var(&Hold, "hold", 2, "seconds to hold the final image in piped animations")
+	flag.StringVar(&Pipe, "pipe", "", "stream raw rgba animation frames to this command, with {width}, {height} and {fps} replaced")
+	flag.StringVar(&SVGAnim, "svganim", "", "animate svg output with smil or css")
+	flag.BoolVar(&Pop, "pop", false, "show shapes at once instead of fading them in animated svg output")
+	flag.Float64Var(&Batch, "batch", 0, "minimum score improvement per reveal batch in animated svg output")
+	flag.BoolVar(&Compact, "compact", false, "write smaller svg output with rounded coordinates and grouped colors")
+	flag.IntVar(&Precision, "precision", 1, "decimal places of coordinates in compact svg output")
+	flag.BoolVar(&Merge, "merge", false, "merge non-overlapping same color shapes into one path in compact svg output")
+	flag.BoolVar(&SVGMeta, "svgmeta", false, "add data-index, data-type and data-delta attributes to svg shapes")
+	flag.BoolVar(&SVGSort, "svgsort", false, "write svg shapes in order of score improvement")
+	flag.Float64Var(&PlotSize, "plotsize", 200, "size in millimeters of the longer side of hpgl and gcode output")
+	flag.Float64Var(&Hatch, "hatch", 4, "hatch spacing in output pixels of the darkest shapes in hpgl and gcode output")
 	flag.IntVar(&Nth, "nth", 1, "save every Nth frame (put \"%d\" in path)")
+	flag.StringVar(&Track, "track", "", "json path for the shapes of every frame of an image sequence")
+	flag.IntVar(&Age, "age", 100, "hill climb iterations without improvement when refitting shapes to the next frame of a sequence")
 	flag.IntVar(&Repeat, "rep", 0, "add N extra shapes per iteration with reduced search")
 	flag.BoolVar(&V, "v", false, "verbose")
 	flag.BoolVar(&VV, "vv", false, "very verbose")
@@ -89,16 +158,21 @@ func check(err error) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lqip" {
+		runLQIP(os.Args[2:])
+		return
+	}
+
 	// parse and validate arguments
 	flag.Parse()
 	ok := true
 	if Input == "" {
 		ok = errorMessage("ERROR: input argument required")
 	}
-	if len(Outputs) == 0 {
+	if len(Outputs) == 0 && Pipe == "" {
 		ok = errorMessage("ERROR: output argument required")
 	}
-	if len(Configs) == 0 {
+	if len(Configs) == 0 && !primitive.IsDrawing(Input) {
 		ok = errorMessage("ERROR: number argument required")
 	}
 	if len(Configs) == 1 {
@@ -110,6 +184,119 @@ func main() {
 		if config.Count < 1 {
 			ok = errorMessage("ERROR: number argument must be > 0")
 		}
+		switch primitive.ShapeType(config.Mode) {
+		case primitive.ShapeTypeRectangle, primitive.ShapeTypeEllipse, primitive.ShapeTypeCircle:
+		default:
+			for _, output := range Outputs {
+				if strings.ToLower(filepath.Ext(output)) == ".css" {
+					ok = errorMessage("ERROR: css output only supports rectangles, ellipses and circles (modes 2, 3 and 4)")
+				}
+			}
+		}
+	}
+	blend, blendOK := primitive.ParseBlendMode(Blend)
+	if !blendOK {
+		ok = errorMessage("ERROR: unrecognized blend mode")
+	}
+	renderer, rendererOK := primitive.ParseRenderer(Render)
+	if !rendererOK {
+		ok = errorMessage("ERROR: unrecognized renderer")
+	}
+	var duotone *primitive.Duotone
+	if Duotone != "" {
+		colors := strings.Split(Duotone, ",")
+		if len(colors) == 2 && primitive.IsHexColor(colors[0]) && primitive.IsHexColor(colors[1]) {
+			dark := primitive.MakeHexColor(colors[0])
+			light := primitive.MakeHexColor(colors[1])
+			duotone = &primitive.Duotone{Dark: dark, Light: light}
+		} else {
+			ok = errorMessage("ERROR: duotone argument must be two hex colors")
+		}
+	}
+	if Duotone != "" && (Palette != "" || (Colors != "" && ColorMode == "palette")) {
+		ok = errorMessage("ERROR: duotone cannot be combined with a palette")
+	}
+	switch Background {
+	case "", "avg", "median", "dominant", "optimal", "gradient", "none":
+	default:
+		if !primitive.IsHexColor(Background) {
+			ok = errorMessage("ERROR: bg argument must be a hex color, avg, median, dominant, optimal, gradient or none")
+		}
+	}
+	easing, easingOK := primitive.ParseEasing(Easing)
+	if !easingOK {
+		ok = errorMessage("ERROR: unrecognized easing")
+	}
+	if FPS <= 0 || Duration < 0 || Fade < 0 || Hold < 0 {
+		ok = errorMessage("ERROR: animation timing arguments must be positive")
+	}
+	if SVGAnim != "" && SVGAnim != "smil" && SVGAnim != "css" {
+		ok = errorMessage("ERROR: svganim argument must be smil or css")
+	}
+	if Tiles > 1 && (Pipe != "" || SVGAnim != "" || Compact || From != "") {
+		ok = errorMessage("ERROR: tiles cannot be combined with pipe, svganim, compact or from")
+	}
+	if From != "" && (Gray || Duotone != "") {
+		ok = errorMessage("ERROR: from cannot be combined with gray or duotone")
+	}
+	if Canvas != "" && (Tiles > 1 || From != "" || Background == "gradient") {
+		ok = errorMessage("ERROR: canvas cannot be combined with tiles, from or gradient backgrounds")
+	}
+	for _, output := range Outputs {
+		switch strings.ToLower(filepath.Ext(output)) {
+		case ".hpgl", ".plt", ".gcode", ".nc":
+			if Canvas != "" {
+				ok = errorMessage("ERROR: canvas cannot be combined with plotter output")
+			}
+		}
+	}
+	if ColorMode != "palette" && ColorMode != "local" {
+		ok = errorMessage("ERROR: colormode argument must be palette or local")
+	}
+	if _, size := primitive.PaletteSize(Palette); Colors != "" && ColorMode == "palette" && Palette != "" && !size {
+		ok = errorMessage("ERROR: with colors in palette colormode, palette must be the number of colors to extract from the colors image")
+	}
+	if Colors != "" && ColorMode == "local" && Tiles > 1 {
+		ok = errorMessage("ERROR: tiles cannot be combined with local colormode")
+	}
+	sequence := sequencePaths(Input)
+	if sequence != nil {
+		if Tiles > 1 || From != "" || Canvas != "" || Pipe != "" {
+			ok = errorMessage("ERROR: image sequences cannot be combined with tiles, from, canvas or pipe")
+		}
+		for _, output := range Outputs {
+			ext := strings.ToLower(filepath.Ext(output))
+			if !strings.Contains(output, "%") || ext == ".gif" || ext == ".apng" {
+				ok = errorMessage("ERROR: image sequence outputs need a frame number (put \"%d\" in path) and cannot be gif or apng")
+			}
+		}
+	} else if Track != "" {
+		ok = errorMessage("ERROR: track requires an image sequence input")
+	}
+	if Age < 1 {
+		ok = errorMessage("ERROR: age argument must be > 0")
+	}
+	if Compact && SVGAnim != "" {
+		ok = errorMessage("ERROR: compact cannot be combined with svganim")
+	}
+	if (SVGMeta || SVGSort) && (Compact || SVGAnim != "") {
+		ok = errorMessage("ERROR: svgmeta and svgsort cannot be combined with compact or svganim")
+	}
+	if PlotSize <= 0 || Hatch <= 0 {
+		ok = errorMessage("ERROR: plotsize and hatch arguments must be positive")
+	}
+	if Precision < 0 {
+		ok = errorMessage("ERROR: precision argument must be >= 0")
+	}
+	if Speculate < 1 {
+		ok = errorMessage("ERROR: speculate argument must be > 0")
+	}
+	// animated svg fades groups in, and a group isolates its shapes' blending
+	if SVGAnim != "" && blend != primitive.BlendNormal {
+		ok = errorMessage("ERROR: svganim cannot be combined with blend modes")
+	}
+	if Tiles > 1 && (blend != primitive.BlendNormal || Background == "gradient") {
+		ok = errorMessage("ERROR: tiles cannot be combined with blend modes or gradient backgrounds")
 	}
 	if !ok {
 		fmt.Println("Usage: primitive [OPTIONS] -i input -o output -n count")
@@ -126,15 +313,109 @@ func main() {
 	}
 
 	// seed random number generator
-	rand.Seed(time.Now().UTC().UnixNano())
+	if Seed == 0 {
+		rand.Seed(time.Now().UTC().UnixNano())
+	} else {
+		rand.Seed(Seed)
+	}
 
 	// determine worker count
 	if Workers < 1 {
 		Workers = runtime.NumCPU()
 	}
 
+	animation := primitive.AnimationOptions{
+		FPS: FPS, Duration: Duration, Fade: Fade, Easing: easing, Hold: Hold,
+	}
+	svgAnimation := primitive.SVGAnimation{
+		CSS: SVGAnim == "css", Pop: Pop, Duration: Duration, Fade: Fade,
+		Easing: Easing, ScoreDelta: Batch,
+	}
+	compact := primitive.CompactSVG{Precision: Precision, Merge: Merge}
+	svgOptions := primitive.SVGOptions{Metadata: SVGMeta, SortByImportance: SVGSort}
+	plot := primitive.PlotOptions{Size: PlotSize, Spacing: Hatch}
+
+	// write an output file, choosing the format by extension
+	save := func(model *primitive.Model, path, ext string) {
+		switch ext {
+		default:
+			check(fmt.Errorf("unrecognized file extension: %s", ext))
+		case ".png":
+			check(primitive.SavePNG(path, model.Context.Image()))
+		case ".jpg", ".jpeg":
+			check(primitive.SaveJPG(path, model.Context.Image(), 95))
+		case ".webp":
+			check(primitive.SaveWebP(path, model.Context.Image()))
+		case ".tif", ".tiff":
+			check(primitive.SaveTIFF(path, model.Context.Image()))
+		case ".bmp":
+			check(primitive.SaveBMP(path, model.Context.Image()))
+		case ".svg":
+			if SVGAnim != "" {
+				check(primitive.SaveFile(path, model.AnimatedSVG(svgAnimation)))
+			} else if Compact {
+				svg := model.CompactSVG(compact)
+				full := len(model.SVG())
+				primitive.Log(1, "compact svg is %d bytes, %d (%.0f%%) smaller\n",
+					len(svg), full-len(svg), 100*float64(full-len(svg))/float64(full))
+				check(primitive.SaveFile(path, svg))
+			} else {
+				check(primitive.SaveFile(path, model.SVGWithOptions(svgOptions)))
+			}
+		case ".json":
+			data, err := model.JSON()
+			check(err)
+			check(primitive.SaveFile(path, data))
+		case ".pdf":
+			check(primitive.SaveFile(path, string(model.PDF())))
+		case ".html":
+			check(primitive.SaveFile(path, model.Canvas()))
+		case ".css":
+			css, err := model.CSS()
+			check(err)
+			check(primitive.SaveFile(path, css))
+		case ".eps":
+			check(primitive.SaveFile(path, model.EPS()))
+		case ".hpgl", ".plt":
+			check(primitive.SaveFile(path, model.HPGL(plot)))
+		case ".gcode", ".nc":
+			check(primitive.SaveFile(path, model.GCode(plot)))
+		case ".gif":
+			frames := model.Frames(0.001)
+			check(primitive.SaveGIFImageMagick(path, frames, 50, 250))
+		case ".apng":
+			frames := model.Frames(0.001)
+			check(primitive.SaveAPNG(path, frames, 50, 250))
+		}
+	}
+
+	// render a previous result without its input image
+	if primitive.IsDrawing(Input) {
+		primitive.Log(1, "reading %s\n", Input)
+		drawing, err := primitive.LoadDrawing(Input)
+		check(err)
+		model := drawing.Model(OutputSize)
+		model.SetRenderer(renderer)
+		if SVGAnim != "" && model.Blend != primitive.BlendNormal {
+			check(fmt.Errorf("%s uses blend mode %s, which svganim cannot animate", Input, model.Blend))
+		}
+		for _, output := range Outputs {
+			ext := strings.ToLower(filepath.Ext(output))
+			if output == "-" {
+				ext = ".svg"
+			}
+			primitive.Log(1, "writing %s\n", output)
+			save(model, output, ext)
+		}
+		return
+	}
+
 	// read input image
+	if sequence != nil {
+		Input = sequence[0]
+	}
 	primitive.Log(1, "reading %s\n", Input)
+	primitive.AutoOrient = !NoExif
 	input, err := primitive.LoadImage(Input)
 	check(err)
 
@@ -146,27 +427,106 @@ func main() {
 
 	// determine background color
 	var bg primitive.Color
-	if Background == "" {
+	switch Background {
+	case "", "avg":
 		bg = primitive.MakeColor(primitive.AverageImageColor(input))
-	} else {
+	case "median":
+		bg = primitive.MakeColor(primitive.MedianImageColor(input))
+	case "dominant":
+		bg = primitive.MakeColor(primitive.DominantImageColor(input))
+	case "optimal", "gradient":
+		bg = primitive.MakeColor(primitive.OptimalBackgroundColor(input))
+	case "none":
+		// fully transparent canvas
+		bg = primitive.Color{}
+	default:
 		bg = primitive.MakeHexColor(Background)
 	}
 
+	// read colors image
+	var colors image.Image
+	paletteSource := input
+	if Colors != "" {
+		primitive.Log(1, "reading %s\n", Colors)
+		colors, err = primitive.LoadImage(Colors)
+		check(err)
+		if ColorMode == "palette" {
+			paletteSource = colors
+			if Palette == "" {
+				Palette = "16"
+			}
+		}
+	}
+
+	// parse palette
+	var palette []primitive.Color
+	if Palette != "" {
+		palette, err = primitive.ParsePalette(Palette, paletteSource)
+		check(err)
+	}
+
+	// apply color and search options to a model
+	configure := func(model *primitive.Model) {
+		model.SetBlendMode(blend)
+		if Gray || duotone != nil {
+			model.SetGrayscale(duotone)
+		}
+		if Background == "gradient" {
+			model.SetGradient(primitive.OptimalGradient(model.Target))
+		}
+		model.SetPyramid(Pyramid)
+		model.SetRenderer(renderer)
+		if palette != nil {
+			model.SetPalette(palette)
+		}
+		if colors != nil && ColorMode == "local" {
+			model.SetColorSource(colors)
+		}
+	}
+
+	if Tiles > 1 {
+		runTiled(input, bg, configure)
+		return
+	}
+
 	// run algorithm
 	model := primitive.NewModel(input, bg, OutputSize, Workers)
+	configure(model)
+	if Canvas != "" {
+		model.SetBaseImage(loadCanvas(input))
+		model.BaseHref = CanvasHref
+	}
+	if Seed != 0 {
+		model.Seed(Seed)
+	}
+	if sequence != nil {
+		runSequence(sequence, model, configure, save)
+		return
+	}
+	if From != "" {
+		primitive.Log(1, "reading %s\n", From)
+		drawing, err := primitive.LoadDrawing(From)
+		check(err)
+		check(model.Replay(drawing))
+	}
 	primitive.Log(1, "%d: t=%.3f, score=%.6f\n", 0, 0.0, model.Score)
 	start := time.Now()
 	frame := 0
 	for j, config := range Configs {
-		primitive.Log(1, "count=%d, mode=%d, alpha=%d, repeat=%d\n",
-			config.Count, config.Mode, config.Alpha, config.Repeat)
-
-		for i := 0; i < config.Count; i++ {
-			frame++
+		primitive.Log(1, "count=%d, mode=%d, alpha=%s, repeat=%d\n",
+			config.Count, config.Mode, alphaString(config.Alpha), config.Repeat)
 
-			// find optimal shape and add it to the model
+		for i := 0; i < config.Count; {
+			// find optimal shape(s) and add them to the model
 			t := time.Now()
-			n := model.Step(primitive.ShapeType(config.Mode), config.Alpha, config.Repeat)
+			max := Speculate
+			if max > config.Count-i {
+				max = config.Count - i
+			}
+			n, placed := model.StepSpeculative(primitive.ShapeType(config.Mode), config.Alpha, config.Repeat, max)
+			i += placed
+			prev := frame
+			frame += placed
 			nps := primitive.NumberString(float64(n) / time.Since(t).Seconds())
 			elapsed := time.Since(start).Seconds()
 			primitive.Log(1, "%d: t=%.3f, score=%.6f, n=%d, n/s=%s\n", frame, elapsed, model.Score, n, nps)
@@ -178,30 +538,125 @@ func main() {
 					ext = ".svg"
 				}
 				percent := strings.Contains(output, "%")
-				saveFrames := percent && ext != ".gif"
-				saveFrames = saveFrames && frame%Nth == 0
-				last := j == len(Configs)-1 && i == config.Count-1
+				saveFrames := percent && ext != ".gif" && ext != ".apng"
+				saveFrames = saveFrames && frame/Nth > prev/Nth
+				last := j == len(Configs)-1 && i == config.Count
 				if saveFrames || last {
 					path := output
 					if percent {
 						path = fmt.Sprintf(output, frame)
 					}
 					primitive.Log(1, "writing %s\n", path)
-					switch ext {
-					default:
-						check(fmt.Errorf("unrecognized file extension: %s", ext))
-					case ".png":
-						check(primitive.SavePNG(path, model.Context.Image()))
-					case ".jpg", ".jpeg":
-						check(primitive.SaveJPG(path, model.Context.Image(), 95))
-					case ".svg":
-						check(primitive.SaveFile(path, model.SVG()))
-					case ".gif":
-						frames := model.Frames(0.001)
-						check(primitive.SaveGIFImageMagick(path, frames, 50, 250))
-					}
+					save(model, path, ext)
 				}
 			}
 		}
 	}
+
+	// stream animation frames to an external encoder
+	if Pipe != "" {
+		primitive.Log(1, "piping frames to %s\n", Pipe)
+		w, err := primitive.NewPipeWriter(Pipe, model.Sw, model.Sh, FPS)
+		check(err)
+		check(model.Animate(w, animation))
+		check(w.Close())
+	}
+
+	// report alpha distribution
+	for _, config := range Configs {
+		if config.Alpha == 0 {
+			const bins = 8
+			for i, count := range model.AlphaCounts(bins) {
+				lo, hi := i*256/bins, (i+1)*256/bins-1
+				primitive.Log(1, "alpha %d-%d: %d shapes\n", lo, hi, count)
+			}
+			break
+		}
+	}
+
+	logPalette(model.Palette, model.PaletteCounts())
+}
+
+func alphaString(alpha int) string {
+	if alpha == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(alpha)
+}
+
+// logPalette reports how many shapes use each palette color.
+func logPalette(palette []primitive.Color, counts []int) {
+	for i, count := range counts {
+		c := palette[i]
+		primitive.Log(1, "palette #%02x%02x%02x: %d shapes\n", c.R, c.G, c.B, count)
+	}
+}
+
+// loadCanvas returns the starting canvas named by the canvas flag.
+func loadCanvas(input image.Image) image.Image {
+	if Canvas == "blur" || strings.HasPrefix(Canvas, "blur:") {
+		factor := 16
+		if Canvas != "blur" {
+			n, err := strconv.Atoi(Canvas[5:])
+			if err != nil || n < 1 {
+				check(fmt.Errorf("invalid canvas blur factor: %s", Canvas[5:]))
+			}
+			factor = n
+		}
+		return primitive.BlurImage(input, factor)
+	}
+	primitive.Log(1, "reading %s\n", Canvas)
+	im, err := primitive.LoadImage(Canvas)
+	check(err)
+	return im
+}
+
+func runTiled(input image.Image, bg primitive.Color, configure func(*primitive.Model)) {
+	model := primitive.NewTiledModel(input, bg, OutputSize, Tiles, Tiles, Overlap, Workers)
+	for _, tile := range model.Tiles {
+		configure(tile.Model)
+	}
+	if Seed != 0 {
+		model.Seed(Seed)
+	}
+	primitive.Log(1, "%d: t=%.3f, score=%.6f\n", 0, 0.0, model.Score)
+	start := time.Now()
+	frame := 0
+	for _, config := range Configs {
+		primitive.Log(1, "count=%d, mode=%d, alpha=%s, repeat=%d, tiles=%d\n",
+			config.Count, config.Mode, alphaString(config.Alpha), config.Repeat, len(model.Tiles))
+		for i := 0; i < config.Count; i++ {
+			frame++
+			t := time.Now()
+			n := model.Step(primitive.ShapeType(config.Mode), config.Alpha, config.Repeat)
+			nps := primitive.NumberString(float64(n) / time.Since(t).Seconds())
+			elapsed := time.Since(start).Seconds()
+			primitive.Log(1, "%d: t=%.3f, score=%.6f, n=%d, n/s=%s\n", frame, elapsed, model.Score, n, nps)
+		}
+	}
+	for _, output := range Outputs {
+		ext := strings.ToLower(filepath.Ext(output))
+		if output == "-" {
+			ext = ".svg"
+		}
+		primitive.Log(1, "writing %s\n", output)
+		switch ext {
+		default:
+			check(fmt.Errorf("unsupported file extension for tiles: %s", ext))
+		case ".png":
+			check(primitive.SavePNG(output, model.Image()))
+		case ".jpg", ".jpeg":
+			check(primitive.SaveJPG(output, model.Image(), 95))
+		case ".webp":
+			check(primitive.SaveWebP(output, model.Image()))
+		case ".tif", ".tiff":
+			check(primitive.SaveTIFF(output, model.Image()))
+		case ".bmp":
+			check(primitive.SaveBMP(output, model.Image()))
+		case ".svg":
+			check(primitive.SaveFile(output, model.SVG()))
+		}
+	}
+
+	logPalette(model.Tiles[0].Model.Palette, model.PaletteCounts())
 }
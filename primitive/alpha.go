@@ -0,0 +1,107 @@
+package primitive
+
+import (
+	"image"
+	"math"
+)
+
+// optimalAlpha solves for the alpha that minimizes the error of a shape
+// when its color is also chosen optimally.
+//
+// For normal blending each pixel becomes d + a*(c-d) = d*(1-a) + x with
+// x = a*c, which is linear in a and x. Solving for x leaves a one dimensional
+// least squares problem in a with a closed form solution. The alpha channel
+// has no free color term, so it contributes directly to that solution when
+// the target or canvas is not opaque. The other blend
+// modes are not linear in a, so a golden section search is used instead.
+func (cc *colorConfig) optimalAlpha(target, current *image.RGBA, lines []Scanline) int {
+	if cc.Blend != BlendNormal {
+		return cc.searchAlpha(target, current, lines)
+	}
+	var n int64
+	var e, d, ed, dd [3]int64
+	var uw, ww int64
+	for _, line := range lines {
+		i := target.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			for j := 0; j < 3; j++ {
+				tj := int64(target.Pix[i+j])
+				dj := int64(current.Pix[i+j])
+				e[j] += tj - dj
+				d[j] += dj
+				ed[j] += (tj - dj) * dj
+				dd[j] += dj * dj
+			}
+			ta := int64(target.Pix[i+3])
+			da := int64(current.Pix[i+3])
+			uw += (ta - da) * (255 - da)
+			ww += (255 - da) * (255 - da)
+			n++
+			i += 4
+		}
+	}
+	if n == 0 {
+		return 255
+	}
+	// each channel has its own optimal x, so center each one separately
+	uv := -float64(uw)
+	vv := float64(ww)
+	for j := 0; j < 3; j++ {
+		uv += float64(ed[j]) - float64(e[j])*float64(d[j])/float64(n)
+		vv += float64(dd[j]) - float64(d[j])*float64(d[j])/float64(n)
+	}
+	if vv < float64(n)*1e-6 {
+		// current is flat, so every alpha fits equally well
+		return 255
+	}
+	a := -uv / vv
+	return clampInt(int(math.Floor(a*255+0.5)), 1, 255)
+}
+
+func (cc *colorConfig) searchAlpha(target, current *image.RGBA, lines []Scanline) int {
+	const iterations = 8
+	phi := (math.Sqrt(5) - 1) / 2
+	f := func(a float64) float64 {
+		alpha := clampInt(int(a+0.5), 1, 255)
+		c := computeColorBlend(target, current, lines, alpha, cc.Blend)
+		return blendError(target, current, lines, c, cc.Blend)
+	}
+	lo, hi := 1.0, 255.0
+	x1 := hi - phi*(hi-lo)
+	x2 := lo + phi*(hi-lo)
+	f1, f2 := f(x1), f(x2)
+	for i := 0; i < iterations; i++ {
+		if f1 < f2 {
+			hi, x2, f2 = x2, x1, f1
+			x1 = hi - phi*(hi-lo)
+			f1 = f(x1)
+		} else {
+			lo, x1, f1 = x1, x2, f2
+			x2 = lo + phi*(hi-lo)
+			f2 = f(x2)
+		}
+	}
+	return clampInt(int((lo+hi)/2+0.5), 1, 255)
+}
+
+// blendError returns the squared error over lines after blending c onto
+// current, without modifying current.
+func blendError(target, current *image.RGBA, lines []Scanline, c Color, mode BlendMode) float64 {
+	a := c.A * 0x101
+	b := 0xffff - a
+	s := [3]int{c.R, c.G, c.B}
+	var total float64
+	for _, line := range lines {
+		i := target.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			for j := 0; j < 3; j++ {
+				d := int(current.Pix[i+j])
+				r := (d*b + blend(mode, d, s[j])*a) / 0xffff
+				e := float64(int(target.Pix[i+j]) - r)
+				total += e * e
+			}
+			i += 4
+		}
+	}
+	return total
+}
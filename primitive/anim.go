@@ -0,0 +1,136 @@
+package primitive
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// FrameWriter receives animation frames along with how many seconds each
+// one is shown.
+type FrameWriter interface {
+	WriteFrame(im image.Image, delay float64) error
+	Close() error
+}
+
+// Easing maps the progress of a shape's fade from 0 to 1.
+type Easing func(t float64) float64
+
+func ParseEasing(name string) (Easing, bool) {
+	switch name {
+	case "linear":
+		return func(t float64) float64 { return t }, true
+	case "in":
+		return func(t float64) float64 { return t * t * t }, true
+	case "out":
+		return func(t float64) float64 { return 1 - math.Pow(1-t, 3) }, true
+	case "inout":
+		return func(t float64) float64 {
+			if t < 0.5 {
+				return 4 * t * t * t
+			}
+			return 1 - math.Pow(-2*t+2, 3)/2
+		}, true
+	}
+	return nil, false
+}
+
+type AnimationOptions struct {
+	FPS      float64 // frames per second
+	Duration float64 // seconds over which the shapes appear
+	Fade     float64 // seconds each shape takes to fade in
+	Easing   Easing
+	Hold     float64 // seconds the final image is shown
+}
+
+// Animate renders the model's shapes appearing one after another at even
+// intervals, each fading in over opt.Fade seconds.
+func (model *Model) Animate(w FrameWriter, opt AnimationOptions) error {
+	n := len(model.Shapes)
+	start := func(i int) float64 {
+		return opt.Duration * float64(i) / float64(n)
+	}
+	frameDelay := 1 / opt.FPS
+	base := model.newContext()
+	frame := image.NewRGBA(image.Rect(0, 0, model.Sw, model.Sh))
+	dc := model.contextFor(frame)
+	drawn := 0
+	for k := 0; ; k++ {
+		t := float64(k) * frameDelay
+		for drawn < n && start(drawn)+opt.Fade <= t {
+			model.drawShape(base, model.Shapes[drawn], model.Colors[drawn])
+			drawn++
+		}
+		if drawn == n {
+			return w.WriteFrame(base.Image(), frameDelay+opt.Hold)
+		}
+		draw.Draw(frame, frame.Rect, base.Image(), image.ZP, draw.Src)
+		for i := drawn; i < n && start(i) < t; i++ {
+			p := opt.Easing((t - start(i)) / opt.Fade)
+			c := model.Colors[i]
+			c.A = int(math.Floor(float64(c.A)*p + 0.5))
+			model.drawShape(dc, model.Shapes[i], c)
+		}
+		if err := w.WriteFrame(frame, frameDelay); err != nil {
+			return err
+		}
+	}
+}
+
+// PipeWriter streams frames as raw 8-bit RGBA to the standard input of an
+// external command, such as a video encoder. Frames are repeated as needed
+// to show each one for its delay at a constant frame rate.
+type PipeWriter struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	fps     float64
+	time    float64
+	written int
+	buf     *image.NRGBA
+}
+
+// NewPipeWriter runs command with sh. The placeholders {width}, {height}
+// and {fps} in the command are replaced with the frame size and rate, e.g.
+//
+//	ffmpeg -f rawvideo -pix_fmt rgba -s {width}x{height} -r {fps} -i - out.mp4
+func NewPipeWriter(command string, width, height int, fps float64) (*PipeWriter, error) {
+	command = strings.NewReplacer(
+		"{width}", fmt.Sprint(width),
+		"{height}", fmt.Sprint(height),
+		"{fps}", fmt.Sprint(fps)).Replace(command)
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	buf := image.NewNRGBA(image.Rect(0, 0, width, height))
+	return &PipeWriter{cmd, stdin, fps, 0, 0, buf}, nil
+}
+
+func (p *PipeWriter) WriteFrame(im image.Image, delay float64) error {
+	draw.Draw(p.buf, p.buf.Rect, im, im.Bounds().Min, draw.Src)
+	p.time += delay
+	for target := int(p.time*p.fps + 0.5); p.written < target; p.written++ {
+		if _, err := p.stdin.Write(p.buf.Pix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PipeWriter) Close() error {
+	if err := p.stdin.Close(); err != nil {
+		return err
+	}
+	return p.cmd.Wait()
+}
@@ -0,0 +1,151 @@
+package primitive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type recordWriter struct {
+	frames []*image.RGBA
+	delays []float64
+}
+
+func (r *recordWriter) WriteFrame(im image.Image, delay float64) error {
+	r.frames = append(r.frames, copyRGBA(im.(*image.RGBA)))
+	r.delays = append(r.delays, delay)
+	return nil
+}
+
+func (r *recordWriter) Close() error {
+	return nil
+}
+
+func animationModel(t *testing.T) *Model {
+	im, err := LoadImage("../examples/owl.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := NewModel(im, MakeColor(AverageImageColor(im)), 64, 1)
+	model.Seed(1)
+	for i := 0; i < 5; i++ {
+		model.Step(ShapeTypeTriangle, 128, 0)
+	}
+	return model
+}
+
+func TestAnimate(t *testing.T) {
+	model := animationModel(t)
+	easing, _ := ParseEasing("out")
+	var w recordWriter
+	opt := AnimationOptions{FPS: 10, Duration: 1, Fade: 0.25, Easing: easing, Hold: 2}
+	if err := model.Animate(&w, opt); err != nil {
+		t.Fatal(err)
+	}
+	// the last shape starts at 0.8s and is done by the frame at 1.1s
+	if len(w.frames) != 12 {
+		t.Errorf("got %d frames, want 12", len(w.frames))
+	}
+	last := w.frames[len(w.frames)-1]
+	if !bytes.Equal(last.Pix, model.Context.Image().(*image.RGBA).Pix) {
+		t.Errorf("last frame differs from the final image")
+	}
+	if d := w.delays[len(w.delays)-1]; d != 2.1 {
+		t.Errorf("last frame delay %v, want 2.1", d)
+	}
+}
+
+func TestAPNGWriter(t *testing.T) {
+	model := animationModel(t)
+	var buf bytes.Buffer
+	w := NewAPNGWriter(&buf)
+	frames := model.Frames(0)
+	for _, im := range frames {
+		if err := w.WriteFrame(im, 0.1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// the default image is the first frame
+	im, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(imageToRGBA(im).Pix, imageToRGBA(frames[0]).Pix) {
+		t.Errorf("default image differs from the first frame")
+	}
+
+	// sequence numbers must count up across fcTL and fdAT chunks
+	var kinds []string
+	seq := uint32(0)
+	for i := 8; i < len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		kinds = append(kinds, kind)
+		if kind == "fcTL" || kind == "fdAT" {
+			if got := binary.BigEndian.Uint32(data[i+8:]); got != seq {
+				t.Fatalf("%s has sequence number %d, want %d", kind, got, seq)
+			}
+			seq++
+		}
+		i += n + 12
+	}
+	if kinds[0] != "IHDR" || kinds[1] != "acTL" || kinds[len(kinds)-1] != "IEND" {
+		t.Errorf("unexpected chunk order %v", kinds)
+	}
+}
+
+func TestPipeWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "primitive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "frames.raw")
+	w, err := NewPipeWriter("cat > "+path, 4, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	im := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	w.WriteFrame(im, 0.1)
+	w.WriteFrame(im, 0.25)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.35 seconds at 10 fps rounds to 4 frames
+	if len(data) != 4*4*3*4 {
+		t.Errorf("got %d bytes, want %d", len(data), 4*4*3*4)
+	}
+}
+
+func TestAPNGDelay(t *testing.T) {
+	tests := []struct {
+		delay    float64
+		num, den uint16
+	}{
+		{0.5, 500, 1000},
+		{65.535, 65535, 1000},
+		{100, 10000, 100},
+		{1000, 10000, 10},
+		{10000, 10000, 1},
+	}
+	for _, test := range tests {
+		num, den := apngDelay(test.delay)
+		if num != test.num || den != test.den {
+			t.Errorf("apngDelay(%g) = %d/%d, want %d/%d", test.delay, num, den, test.num, test.den)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package primitive
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+)
+
+// APNGWriter writes an animated PNG. Each frame only stores the region that
+// changed since the previous frame, and repeated frames extend the previous
+// frame's delay instead of being stored again.
+type APNGWriter struct {
+	w        io.Writer
+	frames   []apngFrame
+	previous *image.NRGBA
+}
+
+type apngFrame struct {
+	rect  image.Rectangle
+	data  []byte
+	delay float64
+}
+
+func NewAPNGWriter(w io.Writer) *APNGWriter {
+	return &APNGWriter{w: w}
+}
+
+func (a *APNGWriter) WriteFrame(im image.Image, delay float64) error {
+	b := im.Bounds()
+	frame := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(frame, frame.Rect, im, b.Min, draw.Src)
+	rect := frame.Rect
+	if a.previous != nil {
+		rect = changedRect(a.previous, frame)
+		if rect.Empty() {
+			a.frames[len(a.frames)-1].delay += delay
+			return nil
+		}
+	}
+	data, err := encodeIDAT(frame.SubImage(rect).(*image.NRGBA))
+	if err != nil {
+		return err
+	}
+	a.frames = append(a.frames, apngFrame{rect, data, delay})
+	a.previous = frame
+	return nil
+}
+
+func (a *APNGWriter) Close() error {
+	if len(a.frames) == 0 {
+		return nil
+	}
+	size := a.frames[0].rect.Size()
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(size.X))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(size.Y))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // truecolor with alpha
+	writeChunk(&buf, "IHDR", ihdr)
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(a.frames)))
+	writeChunk(&buf, "acTL", actl)
+	seq := uint32(0)
+	for i, f := range a.frames {
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(f.rect.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(f.rect.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], uint32(f.rect.Min.X))
+		binary.BigEndian.PutUint32(fctl[16:], uint32(f.rect.Min.Y))
+		num, den := apngDelay(f.delay)
+		binary.BigEndian.PutUint16(fctl[20:], num)
+		binary.BigEndian.PutUint16(fctl[22:], den)
+		// dispose op none, blend op source
+		writeChunk(&buf, "fcTL", fctl)
+		seq++
+		if i == 0 {
+			writeChunk(&buf, "IDAT", f.data)
+		} else {
+			fdat := make([]byte, 4+len(f.data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			copy(fdat[4:], f.data)
+			writeChunk(&buf, "fdAT", fdat)
+			seq++
+		}
+	}
+	writeChunk(&buf, "IEND", nil)
+	_, err := a.w.Write(buf.Bytes())
+	return err
+}
+
+// SaveAPNG writes frames, such as those from Model.Frames, as an animated
+// PNG. Delays are in hundredths of a second, as with SaveGIF.
+func SaveAPNG(path string, frames []image.Image, delay, lastDelay int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	a := NewAPNGWriter(file)
+	for i, im := range frames {
+		d := delay
+		if i == len(frames)-1 {
+			d = lastDelay
+		}
+		if err := a.WriteFrame(im, float64(d)/100); err != nil {
+			return err
+		}
+	}
+	return a.Close()
+}
+
+// apngDelay returns a frame delay in seconds as the fraction stored in
+// fcTL, using the finest denominator whose numerator fits in 16 bits so
+// long holds lose precision instead of being cut short.
+func apngDelay(delay float64) (num, den uint16) {
+	for _, den := range []uint16{1000, 100, 10, 1} {
+		if n := math.Floor(delay*float64(den) + 0.5); n <= math.MaxUint16 || den == 1 {
+			return uint16(math.Min(n, math.MaxUint16)), den
+		}
+	}
+	return
+}
+
+func writeChunk(w io.Writer, kind string, data []byte) {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], kind)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+	w.Write(header)
+	w.Write(data)
+	w.Write(footer)
+}
+
+// changedRect returns the bounds of the pixels that differ between a and b.
+func changedRect(a, b *image.NRGBA) image.Rectangle {
+	var r image.Rectangle
+	w := a.Rect.Dx()
+	for y := 0; y < a.Rect.Dy(); y++ {
+		i := a.PixOffset(0, y)
+		if bytes.Equal(a.Pix[i:i+w*4], b.Pix[i:i+w*4]) {
+			continue
+		}
+		x1, x2 := 0, w-1
+		for ; x1 < w && bytes.Equal(a.Pix[i+x1*4:i+x1*4+4], b.Pix[i+x1*4:i+x1*4+4]); x1++ {
+		}
+		for ; x2 > x1 && bytes.Equal(a.Pix[i+x2*4:i+x2*4+4], b.Pix[i+x2*4:i+x2*4+4]); x2-- {
+		}
+		r = r.Union(image.Rect(x1, y, x2+1, y+1))
+	}
+	return r
+}
+
+// encodeIDAT compresses an image as 8-bit RGBA PNG image data, choosing
+// the Sub or Up filter for each row, whichever has the smaller sum.
+func encodeIDAT(im *image.NRGBA) ([]byte, error) {
+	w, h := im.Rect.Dx(), im.Rect.Dy()
+	n := w * 4
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	prev := make([]byte, n)
+	sub := make([]byte, n+1)
+	up := make([]byte, n+1)
+	for y := 0; y < h; y++ {
+		i := im.PixOffset(im.Rect.Min.X, im.Rect.Min.Y+y)
+		row := im.Pix[i : i+n]
+		sub[0], up[0] = 1, 2
+		var sumSub, sumUp int
+		for x := 0; x < n; x++ {
+			var left byte
+			if x >= 4 {
+				left = row[x-4]
+			}
+			sub[x+1] = row[x] - left
+			up[x+1] = row[x] - prev[x]
+			sumSub += absInt(int(int8(sub[x+1])))
+			sumUp += absInt(int(int8(up[x+1])))
+		}
+		line := sub
+		if sumUp < sumSub {
+			line = up
+		}
+		if _, err := zw.Write(line); err != nil {
+			return nil, err
+		}
+		copy(prev, row)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
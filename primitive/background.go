@@ -0,0 +1,180 @@
+package primitive
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+func MedianImageColor(im image.Image) color.NRGBA {
+	rgba := asRGBA(im)
+	var hist [3][256]int
+	var count int
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		a := int(rgba.Pix[i+3])
+		if a == 0 {
+			continue
+		}
+		for j := 0; j < 3; j++ {
+			hist[j][int(rgba.Pix[i+j])*255/a]++
+		}
+		count++
+	}
+	var c [3]uint8
+	for j := range c {
+		total := 0
+		for v, n := range hist[j] {
+			total += n
+			if total*2 >= count {
+				c[j] = uint8(v)
+				break
+			}
+		}
+	}
+	return color.NRGBA{c[0], c[1], c[2], 255}
+}
+
+// DominantImageColor returns the center of the largest k-means cluster.
+func DominantImageColor(im image.Image) color.NRGBA {
+	const k = 5
+	return ExtractPalette(im, k)[0].NRGBA()
+}
+
+// OptimalBackgroundColor returns the opaque color that minimizes the
+// differenceFull score of a uniform canvas. The score is a sum of squares
+// over premultiplied channels, so this is the rounded mean of those
+// channels, which differs from the mean color when the image has alpha.
+func OptimalBackgroundColor(im image.Image) color.NRGBA {
+	rgba := asRGBA(im)
+	var sums [3]int
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		sums[0] += int(rgba.Pix[i+0])
+		sums[1] += int(rgba.Pix[i+1])
+		sums[2] += int(rgba.Pix[i+2])
+	}
+	n := len(rgba.Pix) / 4
+	var c [3]uint8
+	for j := range c {
+		c[j] = uint8((sums[j] + n/2) / n)
+	}
+	return color.NRGBA{c[0], c[1], c[2], 255}
+}
+
+// Gradient is a two color linear gradient background. Its end points are in
+// target image pixel coordinates.
+type Gradient struct {
+	X1, Y1 float64
+	X2, Y2 float64
+	Color1 Color
+	Color2 Color
+}
+
+// OptimalGradient fits a linear gradient to an image. For each candidate
+// direction the colors at both ends are solved by least squares, and the
+// direction with the lowest score is kept.
+func OptimalGradient(im image.Image) *Gradient {
+	const angles = 24
+	target := asRGBA(im)
+	w := target.Bounds().Size().X
+	h := target.Bounds().Size().Y
+	var best *Gradient
+	var bestScore float64
+	for i := 0; i < angles; i++ {
+		g := fitGradient(target, math.Pi*float64(i)/angles)
+		score := differenceFull(target, g.Image(w, h))
+		if best == nil || score < bestScore {
+			best = g
+			bestScore = score
+		}
+	}
+	return best
+}
+
+func fitGradient(target *image.RGBA, angle float64) *Gradient {
+	w := target.Bounds().Size().X
+	h := target.Bounds().Size().Y
+	dx, dy := math.Cos(angle), math.Sin(angle)
+	// project the image corners to find where the gradient starts and ends
+	cx, cy := float64(w)/2, float64(h)/2
+	extent := (math.Abs(dx)*float64(w) + math.Abs(dy)*float64(h)) / 2
+	g := &Gradient{cx - dx*extent, cy - dy*extent, cx + dx*extent, cy + dy*extent, Color{}, Color{}}
+	var n, st, stt float64
+	var sv, stv [3]float64
+	for y := 0; y < h; y++ {
+		i := target.PixOffset(0, y)
+		for x := 0; x < w; x++ {
+			t := g.at(float64(x)+0.5, float64(y)+0.5)
+			n++
+			st += t
+			stt += t * t
+			for j := 0; j < 3; j++ {
+				v := float64(target.Pix[i+j])
+				sv[j] += v
+				stv[j] += t * v
+			}
+			i += 4
+		}
+	}
+	var c1, c2 [3]int
+	for j := 0; j < 3; j++ {
+		slope := (n*stv[j] - st*sv[j]) / (n*stt - st*st)
+		intercept := (sv[j] - slope*st) / n
+		c1[j] = clampInt(int(math.Floor(intercept+0.5)), 0, 255)
+		c2[j] = clampInt(int(math.Floor(intercept+slope+0.5)), 0, 255)
+	}
+	g.Color1 = Color{c1[0], c1[1], c1[2], 255}
+	g.Color2 = Color{c2[0], c2[1], c2[2], 255}
+	return g
+}
+
+// at returns the position of a point along the gradient, from 0 to 1.
+func (g *Gradient) at(x, y float64) float64 {
+	dx, dy := g.X2-g.X1, g.Y2-g.Y1
+	t := ((x-g.X1)*dx + (y-g.Y1)*dy) / (dx*dx + dy*dy)
+	return clamp(t, 0, 1)
+}
+
+func (g *Gradient) Image(w, h int) *image.RGBA {
+	im := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := g.color(g.at(float64(x)+0.5, float64(y)+0.5))
+			im.SetRGBA(x, y, color.RGBA{uint8(c.R), uint8(c.G), uint8(c.B), 255})
+		}
+	}
+	return im
+}
+
+func (g *Gradient) color(t float64) Color {
+	lerp := func(a, b int) int {
+		return int(math.Floor(float64(a) + float64(b-a)*t + 0.5))
+	}
+	c1, c2 := g.Color1, g.Color2
+	return Color{lerp(c1.R, c2.R), lerp(c1.G, c2.G), lerp(c1.B, c2.B), 255}
+}
+
+// Map returns a copy of the gradient with f applied to both colors.
+func (g *Gradient) Map(f func(Color) Color) *Gradient {
+	a := *g
+	a.Color1 = f(g.Color1)
+	a.Color2 = f(g.Color2)
+	return &a
+}
+
+func (g *Gradient) pattern(scale float64) gg.Pattern {
+	p := gg.NewLinearGradient(g.X1*scale, g.Y1*scale, g.X2*scale, g.Y2*scale)
+	p.AddColorStop(0, g.Color1.NRGBA())
+	p.AddColorStop(1, g.Color2.NRGBA())
+	return p
+}
+
+func (g *Gradient) SVG(id string, scale float64) string {
+	c1, c2 := g.Color1, g.Color2
+	return fmt.Sprintf(
+		"<linearGradient id=\"%s\" gradientUnits=\"userSpaceOnUse\" x1=\"%f\" y1=\"%f\" x2=\"%f\" y2=\"%f\"><stop offset=\"0\" stop-color=\"#%02x%02x%02x\" /><stop offset=\"1\" stop-color=\"#%02x%02x%02x\" /></linearGradient>",
+		id, g.X1*scale, g.Y1*scale, g.X2*scale, g.Y2*scale,
+		c1.R, c1.G, c1.B, c2.R, c2.G, c2.B)
+}
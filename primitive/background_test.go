@@ -0,0 +1,34 @@
+package primitive
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// TestBackgroundSubImage checks that background colors only use the pixels
+// inside a sub-image's bounds.
+func TestBackgroundSubImage(t *testing.T) {
+	im := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(im, image.Rect(0, 0, 10, 20), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.ZP, draw.Src)
+	draw.Draw(im, image.Rect(10, 0, 20, 20), image.NewUniform(color.RGBA{0, 0, 255, 255}), image.ZP, draw.Src)
+	sub := im.SubImage(image.Rect(10, 5, 20, 15))
+	blue := color.NRGBA{0, 0, 255, 255}
+	if c := AverageImageColor(sub); c != blue {
+		t.Errorf("average color %v, want %v", c, blue)
+	}
+	if c := MedianImageColor(sub); c != blue {
+		t.Errorf("median color %v, want %v", c, blue)
+	}
+	if c := DominantImageColor(sub); c != blue {
+		t.Errorf("dominant color %v, want %v", c, blue)
+	}
+	if c := OptimalBackgroundColor(sub); c != blue {
+		t.Errorf("optimal color %v, want %v", c, blue)
+	}
+	g := OptimalGradient(sub)
+	if c := MakeColor(blue); g.Color1 != c || g.Color2 != c {
+		t.Errorf("gradient colors %v and %v, want %v", g.Color1, g.Color2, c)
+	}
+}
@@ -0,0 +1,92 @@
+package primitive
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// SetBaseImage starts the model from an image instead of the background
+// color, such as a previous render or a blurred copy of the target, so
+// shapes paint over it. The image is stretched to the target size for
+// scoring and to the output size for output.
+func (model *Model) SetBaseImage(im image.Image) {
+	model.BaseImage = im
+	model.Gradient = nil
+	size := model.Target.Bounds().Size()
+	model.setCanvas(model.baseImage(size.X, size.Y))
+}
+
+// baseImage returns the base image scaled to w x h, in grayscale for
+// grayscale models.
+func (model *Model) baseImage(w, h int) *image.RGBA {
+	im := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.BiLinear.Scale(im, im.Rect, model.BaseImage, model.BaseImage.Bounds(), xdraw.Src, nil)
+	if model.Gray {
+		grayRGBA(im)
+	}
+	return im
+}
+
+// outputBase returns the base image at the output size with output
+// colors.
+func (model *Model) outputBase() *image.RGBA {
+	im := model.baseImage(model.Sw, model.Sh)
+	if model.Duotone != nil {
+		for i := 0; i < len(im.Pix); i += 4 {
+			p := im.Pix[i : i+4]
+			c := model.Duotone.Map(Color{int(p[0]), int(p[1]), int(p[2]), int(p[3])})
+			p[0], p[1], p[2] = uint8(c.R), uint8(c.G), uint8(c.B)
+		}
+	}
+	return im
+}
+
+// baseURL returns model.BaseHref if it is set, or the base image as a PNG
+// data URI otherwise.
+func (model *Model) baseURL() string {
+	if model.BaseHref != "" {
+		return model.BaseHref
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, model.outputBase())
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// baseRGB returns the output base image as zlib compressed RGB rows, the
+// image data of PDF and PostScript.
+func (model *Model) baseRGB() []byte {
+	im := model.outputBase()
+	rgb := make([]byte, 0, len(im.Pix)/4*3)
+	for i := 0; i < len(im.Pix); i += 4 {
+		rgb = append(rgb, im.Pix[i:i+3]...)
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(rgb)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// baseSVG returns an <image> element with the base image.
+func (model *Model) baseSVG() string {
+	href := model.baseURL()
+	return fmt.Sprintf("<image xmlns:xlink=\"http://www.w3.org/1999/xlink\" x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" preserveAspectRatio=\"none\" xlink:href=\"%s\" />",
+		model.Sw, model.Sh, href)
+}
+
+// BlurImage returns a blurred copy of im, made by scaling it down by
+// factor and back up.
+func BlurImage(im image.Image, factor int) image.Image {
+	b := im.Bounds()
+	small := image.NewRGBA(image.Rect(0, 0, maxInt(b.Dx()/factor, 1), maxInt(b.Dy()/factor, 1)))
+	xdraw.BiLinear.Scale(small, small.Rect, im, b, xdraw.Src, nil)
+	result := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	xdraw.BiLinear.Scale(result, result.Rect, small, small.Rect, xdraw.Src, nil)
+	return result
+}
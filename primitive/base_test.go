@@ -0,0 +1,93 @@
+package primitive
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestSetBaseImage(t *testing.T) {
+	target := uniformRGBA(image.Rect(0, 0, 32, 16), color.NRGBA{200, 40, 40, 255})
+	base := uniformRGBA(image.Rect(0, 0, 8, 8), color.NRGBA{200, 40, 40, 255})
+	model := NewModel(target, Color{0, 0, 0, 255}, 64, 1)
+	before := model.Score
+	model.SetBaseImage(base)
+	if model.Score >= before || model.Score > 1e-6 {
+		t.Errorf("score %f, want ~0 (was %f)", model.Score, before)
+	}
+	if got := model.Current.RGBAAt(31, 15); got != (color.RGBA{200, 40, 40, 255}) {
+		t.Errorf("current is %v, want the base image", got)
+	}
+	if got := model.Context.Image().At(63, 31); got != (color.RGBA{200, 40, 40, 255}) {
+		t.Errorf("output is %v, want the base image", got)
+	}
+
+	model.SetGrayscale(nil)
+	if c := model.Current.RGBAAt(0, 0); c.R != c.G || c.G != c.B {
+		t.Errorf("grayscale base image is %v", c)
+	}
+
+	svg := model.SVG()
+	if !strings.Contains(svg, `width="64" height="32" preserveAspectRatio="none" xlink:href="data:image/png;base64,`) {
+		t.Errorf("svg does not embed the base image:\n%s", svg)
+	}
+	model.BaseHref = "base.png"
+	if svg := model.SVG(); !strings.Contains(svg, `xlink:href="base.png"`) {
+		t.Errorf("svg does not reference the base image:\n%s", svg)
+	}
+}
+
+func TestBlurImage(t *testing.T) {
+	im := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 32; x < 64; x++ {
+			im.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+		}
+	}
+	blurred := BlurImage(im, 16).(*image.RGBA)
+	if blurred.Bounds() != im.Bounds() {
+		t.Fatalf("got bounds %v, want %v", blurred.Bounds(), im.Bounds())
+	}
+	if c := blurred.RGBAAt(32, 32); c.R == 0 || c.R == 255 {
+		t.Errorf("edge is not blurred: %v", c)
+	}
+}
+
+func TestBaseImageExports(t *testing.T) {
+	w := NewWorker(uniformRGBA(image.Rect(0, 0, 16, 8), color.Black))
+	model := &Model{Sw: 32, Sh: 16, Scale: 2,
+		Background: Color{255, 255, 255, 255},
+		BaseImage:  uniformRGBA(image.Rect(0, 0, 4, 4), color.NRGBA{0, 128, 0, 255}),
+		Shapes:     []Shape{&Rectangle{w, 0, 0, 3, 3}},
+		Colors:     []Color{{255, 0, 0, 255}},
+	}
+	pdf := string(model.PDF())
+	for _, want := range []string{"/Subtype /Image /Width 32 /Height 16", "/XObject << /Im0 ", "/Im0 Do"} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("pdf does not contain %q", want)
+		}
+	}
+	if strings.Contains(pdf, "re f") {
+		t.Error("pdf fills the background color over the base image")
+	}
+	eps := model.EPS()
+	if !strings.Contains(eps, "/ASCII85Decode filter /FlateDecode filter >> image\n") || !strings.Contains(eps, "~>\ngrestore\n") {
+		t.Errorf("eps does not draw the base image")
+	}
+	if strings.Contains(eps, "rectfill") {
+		t.Error("eps fills the background color over the base image")
+	}
+	html := model.Canvas()
+	if !strings.Contains(html, "c.drawImage(b,0,0,32,16);") || !strings.Contains(html, "b.src='data:image/png;base64,") {
+		t.Errorf("html does not draw the base image:\n%s", html)
+	}
+	model.BaseHref = "base.png"
+	css, err := model.CSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(css, "url(base.png) 0 0/32px 16px no-repeat}\n") {
+		t.Errorf("css does not end with the base image layer:\n%s", css)
+	}
+}
@@ -0,0 +1,219 @@
+package primitive
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+type BlendMode int
+
+const (
+	BlendNormal BlendMode = iota
+	BlendMultiply
+	BlendScreen
+	BlendAdd
+	BlendDifference
+)
+
+var blendModeNames = []string{"normal", "multiply", "screen", "add", "difference"}
+
+func ParseBlendMode(name string) (BlendMode, bool) {
+	name = strings.ToLower(name)
+	for i, n := range blendModeNames {
+		if n == name {
+			return BlendMode(i), true
+		}
+	}
+	return BlendNormal, false
+}
+
+func (mode BlendMode) String() string {
+	return blendModeNames[mode]
+}
+
+// CSS returns the mix-blend-mode keyword matching this blend mode.
+func (mode BlendMode) CSS() string {
+	if mode == BlendAdd {
+		return "plus-lighter"
+	}
+	return mode.String()
+}
+
+func blend(mode BlendMode, d, s int) int {
+	switch mode {
+	case BlendMultiply:
+		return d * s / 255
+	case BlendScreen:
+		return d + s - d*s/255
+	case BlendAdd:
+		return minInt(d+s, 255)
+	case BlendDifference:
+		if d > s {
+			return d - s
+		}
+		return s - d
+	}
+	return s
+}
+
+func computeColorBlend(target, current *image.RGBA, lines []Scanline, alpha int, mode BlendMode) Color {
+	if mode == BlendNormal {
+		return computeColor(target, current, lines, alpha)
+	}
+	if mode == BlendDifference {
+		return computeDifferenceColor(target, current, lines, alpha)
+	}
+	// the blended result is linear in the source color s:
+	// result = d + k * s - e, so s is solved by least squares
+	var num, den [3]float64
+	a := float64(alpha) / 255
+	for _, line := range lines {
+		i := target.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			for j := 0; j < 3; j++ {
+				t := float64(target.Pix[i+j])
+				d := float64(current.Pix[i+j])
+				var k, r float64
+				switch mode {
+				case BlendMultiply:
+					k = a * d / 255
+					r = t - d*(1-a)
+				case BlendScreen:
+					k = a * (1 - d/255)
+					r = t - d
+				case BlendAdd:
+					k = a
+					r = t - d
+				}
+				num[j] += k * r
+				den[j] += k * k
+			}
+			i += 4
+		}
+	}
+	var c [3]int
+	for j := range c {
+		if den[j] > 0 {
+			c[j] = clampInt(int(math.Floor(num[j]/den[j]+0.5)), 0, 255)
+		}
+	}
+	return Color{c[0], c[1], c[2], alpha}
+}
+
+// computeDifferenceColor finds the exact least squares source color for the
+// difference blend mode. The error for each pixel is a quadratic in s on
+// either side of d, so per-channel sums are bucketed by d and every possible
+// s is evaluated with prefix sums.
+func computeDifferenceColor(target, current *image.RGBA, lines []Scanline, alpha int) Color {
+	type bucket struct {
+		n, p, pp, q, qq float64
+	}
+	var buckets [3][256]bucket
+	a := float64(alpha) / 255
+	count := 0
+	for _, line := range lines {
+		i := target.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			for j := 0; j < 3; j++ {
+				t := float64(target.Pix[i+j])
+				d := current.Pix[i+j]
+				u := t - float64(d)*(1-a)
+				p := u - a*float64(d)
+				q := u + a*float64(d)
+				b := &buckets[j][d]
+				b.n++
+				b.p += p
+				b.pp += p * p
+				b.q += q
+				b.qq += q * q
+			}
+			count++
+			i += 4
+		}
+	}
+	if count == 0 {
+		return Color{}
+	}
+	var c [3]int
+	for j := range c {
+		// above holds sums for d >= s, below holds sums for d < s
+		var above, below bucket
+		for _, b := range buckets[j] {
+			above.n += b.n
+			above.p += b.p
+			above.pp += b.pp
+		}
+		best := math.Inf(1)
+		for s := 0; s < 256; s++ {
+			fs := float64(s)
+			e := above.pp + 2*a*fs*above.p + a*a*fs*fs*above.n
+			e += below.qq - 2*a*fs*below.q + a*a*fs*fs*below.n
+			if e < best {
+				best = e
+				c[j] = s
+			}
+			b := buckets[j][s]
+			above.n -= b.n
+			above.p -= b.p
+			above.pp -= b.pp
+			below.n += b.n
+			below.q += b.q
+			below.qq += b.qq
+		}
+	}
+	return Color{c[0], c[1], c[2], alpha}
+}
+
+func drawLinesBlend(im *image.RGBA, c Color, lines []Scanline, mode BlendMode) {
+	if mode == BlendNormal {
+		drawLines(im, c, lines)
+		return
+	}
+	const m = 0xffff
+	sa := uint32(c.A) * 0x101
+	for _, line := range lines {
+		a := sa * line.Alpha / m
+		b := m - a
+		i := im.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			dr := int(im.Pix[i+0])
+			dg := int(im.Pix[i+1])
+			db := int(im.Pix[i+2])
+			da := uint32(im.Pix[i+3])
+			fr := uint32(blend(mode, dr, c.R))
+			fg := uint32(blend(mode, dg, c.G))
+			fb := uint32(blend(mode, db, c.B))
+			im.Pix[i+0] = uint8((uint32(dr)*b + fr*a) / m)
+			im.Pix[i+1] = uint8((uint32(dg)*b + fg*a) / m)
+			im.Pix[i+2] = uint8((uint32(db)*b + fb*a) / m)
+			im.Pix[i+3] = uint8((da*b + 255*a) / m)
+			i += 4
+		}
+	}
+}
+
+// maskLines converts the alpha channel of a rendered mask into scanlines
+// so that anti-aliased shapes can be composited with drawLinesBlend.
+func maskLines(mask *image.RGBA) []Scanline {
+	var lines []Scanline
+	size := mask.Bounds().Size()
+	for y := 0; y < size.Y; y++ {
+		i := mask.PixOffset(0, y)
+		for x := 0; x < size.X; {
+			a := mask.Pix[i+3]
+			if a == 0 {
+				x++
+				i += 4
+				continue
+			}
+			x1 := x
+			for x < size.X && mask.Pix[i+3] == a {
+				x++
+				i += 4
+			}
+			lines = append(lines, Scanline{y, x1, x - 1, uint32(a) * 0x101})
+		}
+	}
+	return lines
+}
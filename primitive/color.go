@@ -3,6 +3,7 @@ package primitive
 import (
 	"fmt"
 	"image/color"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +16,19 @@ func MakeColor(c color.Color) Color {
 	return Color{int(r / 257), int(g / 257), int(b / 257), int(a / 257)}
 }
 
+// IsHexColor reports whether x is a color MakeHexColor accepts: 3, 4, 6
+// or 8 hex digits with an optional leading #.
+func IsHexColor(x string) bool {
+	x = strings.TrimPrefix(x, "#")
+	switch len(x) {
+	case 3, 4, 6, 8:
+	default:
+		return false
+	}
+	_, err := strconv.ParseUint(x, 16, 32)
+	return err == nil
+}
+
 func MakeHexColor(x string) Color {
 	x = strings.Trim(x, "#")
 	var r, g, b, a int
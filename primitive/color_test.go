@@ -0,0 +1,35 @@
+package primitive
+
+import "testing"
+
+func TestMakeHexColor(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want Color
+	}{
+		{"#fff", Color{255, 255, 255, 255}},
+		{"123", Color{0x11, 0x22, 0x33, 255}},
+		{"1238", Color{0x11, 0x22, 0x33, 0x88}},
+		{"#00ff80", Color{0, 255, 128, 255}},
+		{"c0ffee", Color{0xc0, 0xff, 0xee, 255}},
+		{"#11223344", Color{0x11, 0x22, 0x33, 0x44}},
+	}
+	for _, test := range tests {
+		if got := MakeHexColor(test.hex); got != test.want {
+			t.Errorf("MakeHexColor(%q) = %v, want %v", test.hex, got, test.want)
+		}
+	}
+}
+
+func TestIsHexColor(t *testing.T) {
+	for _, x := range []string{"#fff", "1238", "#00ff80", "11223344"} {
+		if !IsHexColor(x) {
+			t.Errorf("IsHexColor(%q) = false", x)
+		}
+	}
+	for _, x := range []string{"", "red", "#12", "12345", "#gggggg", "+12", "##fff"} {
+		if IsHexColor(x) {
+			t.Errorf("IsHexColor(%q) = true", x)
+		}
+	}
+}
@@ -5,6 +5,48 @@ import (
 	"math"
 )
 
+// colorConfig holds the per-run settings that determine how shape colors
+// are chosen and scored. A Model shares its settings with all of its Workers.
+type colorConfig struct {
+	Blend   BlendMode
+	Palette []Color
+	Gray    bool
+	Source  *rowSums
+}
+
+func (cc *colorConfig) computeColor(target, current, buffer *image.RGBA, lines []Scanline, alpha int, score float64) Color {
+	if alpha == 0 {
+		alpha = cc.optimalAlpha(target, current, lines)
+	}
+	var color Color
+	if cc.Source != nil {
+		color = cc.Source.mean(lines, alpha)
+	} else if cc.Gray && cc.Blend == BlendNormal {
+		color = computeColorGray(target, current, lines, alpha)
+	} else {
+		color = computeColorBlend(target, current, lines, alpha, cc.Blend)
+	}
+	if len(cc.Palette) > 0 {
+		color = cc.paletteColor(target, current, buffer, lines, color, score)
+	}
+	return color
+}
+
+func (cc *colorConfig) drawLines(im *image.RGBA, c Color, lines []Scanline) {
+	if cc.Gray && cc.Blend == BlendNormal {
+		drawLinesGray(im, c, lines)
+	} else {
+		drawLinesBlend(im, c, lines, cc.Blend)
+	}
+}
+
+func (cc *colorConfig) difference(target, before, after *image.RGBA, score float64, lines []Scanline) float64 {
+	if cc.Gray {
+		return differencePartialGray(target, before, after, score, lines)
+	}
+	return differencePartial(target, before, after, score, lines)
+}
+
 func computeColor(target, current *image.RGBA, lines []Scanline, alpha int) Color {
 	var rsum, gsum, bsum, count int64
 	a := 0x101 * 255 / alpha
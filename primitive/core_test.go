@@ -0,0 +1,49 @@
+package primitive
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDifferencePartial(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	target := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	rnd.Read(target.Pix)
+	current := uniformRGBA(target.Bounds(), color.NRGBA{40, 80, 120, 255})
+	score := differenceFull(target, current)
+	worker := NewWorker(target)
+	worker.Rnd = rnd
+	for _, st := range kernelShapeTypes {
+		for i := 0; i < 20; i++ {
+			lines := worker.RandomState(st, 128).Shape.Rasterize()
+			c := Color{rnd.Intn(256), rnd.Intn(256), rnd.Intn(256), rnd.Intn(255) + 1}
+			after := copyRGBA(current)
+			drawLines(after, c, lines)
+			got := differencePartial(target, current, after, score, lines)
+			want := differenceFull(target, after)
+			if math.Abs(got-want) > 1e-6 {
+				t.Fatalf("shape type %d: partial %v, full %v", st, got, want)
+			}
+			current, score = after, want
+		}
+	}
+}
+
+func TestStepSearch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	target := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	rnd.Read(target.Pix)
+	model := NewModel(target, Color{128, 128, 128, 255}, 32, 1)
+	model.Seed(1)
+	before := model.Score
+	// the default search evaluates well over a thousand states
+	if n := model.StepSearch(ShapeTypeTriangle, 128, 10, 1, 1); n > 100 {
+		t.Errorf("StepSearch evaluated %d states", n)
+	}
+	if len(model.Shapes) != 1 || model.Score >= before {
+		t.Errorf("StepSearch added %d shapes, score %f, was %f", len(model.Shapes), model.Score, before)
+	}
+}
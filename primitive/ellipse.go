@@ -9,7 +9,7 @@ import (
 )
 
 type Ellipse struct {
-	Worker *Worker
+	Worker *Worker `json:"-"`
 	X, Y   int
 	Rx, Ry int
 	Circle bool
@@ -43,11 +43,25 @@ func (c *Ellipse) SVG(attrs string) string {
 		attrs, c.X, c.Y, c.Rx, c.Ry)
 }
 
+func (c *Ellipse) svgPath(precision int) string {
+	return ellipsePath(float64(c.X), float64(c.Y), float64(c.Rx), float64(c.Ry), 0, precision)
+}
+
+func (c *Ellipse) Path(p PathWriter) {
+	writeEllipse(p, float64(c.X), float64(c.Y), float64(c.Rx), float64(c.Ry), 0)
+}
+
 func (c *Ellipse) Copy() Shape {
 	a := *c
 	return &a
 }
 
+func (c *Ellipse) Scaled(worker *Worker, scale float64) Shape {
+	return &Ellipse{worker,
+		scaleInt(c.X, scale), scaleInt(c.Y, scale),
+		scaleSize(c.Rx, scale), scaleSize(c.Ry, scale), c.Circle}
+}
+
 func (c *Ellipse) Mutate() {
 	w := c.Worker.W
 	h := c.Worker.H
@@ -100,7 +114,7 @@ func (c *Ellipse) Rasterize() []Scanline {
 }
 
 type RotatedEllipse struct {
-	Worker *Worker
+	Worker *Worker `json:"-"`
 	X, Y   float64
 	Rx, Ry float64
 	Angle  float64
@@ -130,11 +144,25 @@ func (c *RotatedEllipse) SVG(attrs string) string {
 		c.X, c.Y, c.Angle, c.Rx, c.Ry, attrs)
 }
 
+func (c *RotatedEllipse) svgPath(precision int) string {
+	return ellipsePath(c.X, c.Y, c.Rx, c.Ry, c.Angle, precision)
+}
+
+func (c *RotatedEllipse) Path(p PathWriter) {
+	writeEllipse(p, c.X, c.Y, c.Rx, c.Ry, c.Angle)
+}
+
 func (c *RotatedEllipse) Copy() Shape {
 	a := *c
 	return &a
 }
 
+func (c *RotatedEllipse) Scaled(worker *Worker, scale float64) Shape {
+	return &RotatedEllipse{worker,
+		c.X * scale, c.Y * scale,
+		c.Rx * scale, c.Ry * scale, c.Angle}
+}
+
 func (c *RotatedEllipse) Mutate() {
 	w := c.Worker.W
 	h := c.Worker.H
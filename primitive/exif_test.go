@@ -0,0 +1,90 @@
+package primitive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+// exifJPEG returns a small JPEG with an APP1 segment holding orientation.
+func exifJPEG(t *testing.T, order binary.ByteOrder, orientation int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	data := buf.Bytes()
+	var result []byte
+	result = append(result, data[:2]...)
+	result = append(result, app1...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for o := 1; o <= 8; o++ {
+			data := exifJPEG(t, order, o)
+			if got := exifOrientation(data); got != o {
+				t.Errorf("%v: got orientation %d, want %d", order, got, o)
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got := exifOrientation([]byte("not a jpeg")); got != 1 {
+		t.Errorf("got orientation %d for non jpeg data", got)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// a 3x2 image whose pixels are numbered 0-5 in the red channel:
+	// 0 1 2
+	// 3 4 5
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		src.Pix[i*4] = uint8(i)
+	}
+	tests := map[int][]uint8{
+		1: {0, 1, 2, 3, 4, 5},
+		2: {2, 1, 0, 5, 4, 3},
+		3: {5, 4, 3, 2, 1, 0},
+		4: {3, 4, 5, 0, 1, 2},
+		5: {0, 3, 1, 4, 2, 5},
+		6: {3, 0, 4, 1, 5, 2},
+		7: {5, 2, 4, 1, 3, 0},
+		8: {2, 5, 1, 4, 0, 3},
+	}
+	for o, want := range tests {
+		dst := imageToRGBA(orient(src, o))
+		size := dst.Bounds().Size()
+		if o >= 5 && (size.X != 2 || size.Y != 3) {
+			t.Errorf("orientation %d: got size %v", o, size)
+		}
+		var got []uint8
+		for i := 0; i < 6; i++ {
+			got = append(got, dst.Pix[i*4])
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("orientation %d: got %v, want %v", o, got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package primitive
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestGolden renders each example with a fixed seed and compares the SVG to
+// testdata. Run with -update after intentional output changes.
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob("../examples/*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".png")
+		t.Run(name, func(t *testing.T) {
+			im, err := LoadImage(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			im = resize.Thumbnail(64, 64, im, resize.Bilinear)
+			model := NewModel(im, MakeColor(AverageImageColor(im)), 256, 1)
+			model.Seed(1)
+			for i := 0; i < 10; i++ {
+				model.Step(ShapeTypeAny, 128, 0)
+			}
+			got := model.SVG()
+			golden := filepath.Join("testdata", name+".svg")
+			if *update {
+				if err := SaveFile(golden, got); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s", golden)
+			}
+		})
+	}
+}
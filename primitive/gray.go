@@ -0,0 +1,120 @@
+package primitive
+
+import (
+	"image"
+	"math"
+)
+
+// Duotone maps gray intensities onto a ramp between two colors. Because the
+// mapping is affine it commutes with normal alpha compositing, so a grayscale
+// model can be rendered in duotone by mapping each shape color independently.
+type Duotone struct {
+	Dark, Light Color
+}
+
+func (d *Duotone) Map(c Color) Color {
+	p := float64(c.R) / 255
+	lerp := func(a, b int) int {
+		return int(math.Floor(float64(a) + float64(b-a)*p + 0.5))
+	}
+	return Color{
+		lerp(d.Dark.R, d.Light.R),
+		lerp(d.Dark.G, d.Light.G),
+		lerp(d.Dark.B, d.Light.B),
+		c.A,
+	}
+}
+
+func luminance(r, g, b int) int {
+	// same weights as color.GrayModel
+	return (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+}
+
+func grayColor(c Color) Color {
+	y := luminance(c.R, c.G, c.B)
+	return Color{y, y, y, c.A}
+}
+
+func grayPalette(palette []Color) []Color {
+	var result []Color
+	for _, c := range palette {
+		result = append(result, grayColor(c))
+	}
+	return result
+}
+
+func grayRGBA(im *image.RGBA) {
+	for i := 0; i < len(im.Pix); i += 4 {
+		y := uint8(luminance(int(im.Pix[i]), int(im.Pix[i+1]), int(im.Pix[i+2])))
+		im.Pix[i+0] = y
+		im.Pix[i+1] = y
+		im.Pix[i+2] = y
+	}
+}
+
+func computeColorGray(target, current *image.RGBA, lines []Scanline, alpha int) Color {
+	var sum, count int64
+	a := 0x101 * 255 / alpha
+	for _, line := range lines {
+		i := target.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			t := int(target.Pix[i])
+			c := int(current.Pix[i])
+			i += 4
+			sum += int64((t-c)*a + c*0x101)
+			count++
+		}
+	}
+	if count == 0 {
+		return Color{}
+	}
+	y := clampInt(int(sum/count)>>8, 0, 255)
+	return Color{y, y, y, alpha}
+}
+
+func drawLinesGray(im *image.RGBA, c Color, lines []Scanline) {
+	const m = 0xffff
+	sy, _, _, sa := c.NRGBA().RGBA()
+	for _, line := range lines {
+		ma := line.Alpha
+		a := (m - sa*ma/m) * 0x101
+		i := im.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			dy := uint32(im.Pix[i+0])
+			da := uint32(im.Pix[i+3])
+			y := uint8((dy*a + sy*ma) / m >> 8)
+			im.Pix[i+0] = y
+			im.Pix[i+1] = y
+			im.Pix[i+2] = y
+			im.Pix[i+3] = uint8((da*a + sa*ma) / m >> 8)
+			i += 4
+		}
+	}
+}
+
+// differencePartialGray is differencePartial for images whose red, green
+// and blue channels are equal, so only one of them needs to be read.
+func differencePartialGray(target, before, after *image.RGBA, score float64, lines []Scanline) float64 {
+	size := target.Bounds().Size()
+	w, h := size.X, size.Y
+	total := uint64(math.Pow(score*255, 2) * float64(w*h*4))
+	for _, line := range lines {
+		i := target.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			ty := int(target.Pix[i])
+			ta := int(target.Pix[i+3])
+			by := int(before.Pix[i])
+			ba := int(before.Pix[i+3])
+			ay := int(after.Pix[i])
+			aa := int(after.Pix[i+3])
+			i += 4
+			dy1 := ty - by
+			da1 := ta - ba
+			dy2 := ty - ay
+			da2 := ta - aa
+			total -= uint64(3*dy1*dy1 + da1*da1)
+			total += uint64(3*dy2*dy2 + da2*da2)
+		}
+	}
+	return math.Sqrt(float64(total)/float64(w*h*4)) / 255
+}
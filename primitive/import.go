@@ -0,0 +1,392 @@
+package primitive
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Drawing is a primitive result read back from SVG or JSON output. Shape
+// coordinates are in the model's units, like the Shapes of the Model that
+// produced it, and the shapes have no Worker until they are added to one.
+type Drawing struct {
+	Width, Height int // output size
+	Scale         float64
+	Background    Color
+	Gradient      *Gradient
+	Blend         BlendMode
+	Shapes        []Shape
+	Colors        []Color
+}
+
+// modelSize returns the size of the target the drawing was made from.
+func (d *Drawing) modelSize() (int, int) {
+	w := int(math.Floor(float64(d.Width)/d.Scale + 0.5))
+	h := int(math.Floor(float64(d.Height)/d.Scale + 0.5))
+	return maxInt(w, 1), maxInt(h, 1)
+}
+
+// Model returns a model with the drawing's shapes that renders at size,
+// for output without the original image. Its target is blank, so it can't
+// be used to add more shapes.
+func (d *Drawing) Model(size int) *Model {
+	w, h := d.modelSize()
+	target := uniformRGBA(image.Rect(0, 0, w, h), color.Black)
+	model := NewModel(target, d.Background, size, 1)
+	model.SetBlendMode(d.Blend)
+	// a color model with the drawing's blend mode always accepts it
+	model.Replay(d)
+	return model
+}
+
+// Replay starts the model over from the drawing's background and adds its
+// shapes with their colors, scaled to the model's target. It is used to
+// continue from an earlier result, possibly against a different target.
+// Drawings store output colors, so grayscale and duotone models, whose
+// output colors differ from the colors they score with, can't replay
+// them. The model's blend mode must match the drawing's, since the SVG
+// output has one blend mode for all shapes.
+func (model *Model) Replay(d *Drawing) error {
+	if model.Gray {
+		return errors.New("can't replay a drawing in grayscale or duotone mode")
+	}
+	if d.Blend != model.Blend {
+		return fmt.Errorf("drawing uses the %s blend mode, not %s", d.Blend, model.Blend)
+	}
+	dw, _ := d.modelSize()
+	scale := float64(model.Target.Bounds().Dx()) / float64(dw)
+	model.Shapes, model.Colors, model.Scores = nil, nil, nil
+	model.Background = d.Background
+	model.BaseImage = nil
+	if d.Gradient != nil {
+		g := *d.Gradient
+		g.X1, g.Y1, g.X2, g.Y2 = g.X1*scale, g.Y1*scale, g.X2*scale, g.Y2*scale
+		model.SetGradient(&g)
+	} else {
+		model.Gradient = nil
+		model.setCanvas(uniformRGBA(model.Target.Bounds(), d.Background.NRGBA()))
+	}
+	worker := model.Workers[0]
+	for i, shape := range d.Shapes {
+		model.AddColored(shape.Scaled(worker, scale), d.Colors[i])
+	}
+	return nil
+}
+
+// IsDrawing reports whether path has the extension of SVG or JSON output.
+func IsDrawing(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".svg" || ext == ".json"
+}
+
+// LoadDrawing reads SVG or JSON output, chosen by the file extension.
+func LoadDrawing(path string) (*Drawing, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return ParseJSON(data)
+	}
+	return ParseSVG(data)
+}
+
+type jsonDrawing struct {
+	Width      int         `json:"width"`
+	Height     int         `json:"height"`
+	Scale      float64     `json:"scale"`
+	Background Color       `json:"background"`
+	Gradient   *Gradient   `json:"gradient,omitempty"`
+	Blend      string      `json:"blend"`
+	Shapes     []jsonShape `json:"shapes"`
+}
+
+type jsonShape struct {
+	Type  string          `json:"type"`
+	Color Color           `json:"color"`
+	Shape json.RawMessage `json:"shape"`
+}
+
+// JSON returns the model's shapes and colors, which ParseJSON reads back.
+func (model *Model) JSON() (string, error) {
+	d := jsonDrawing{model.Sw, model.Sh, model.Scale, model.outputColor(model.Background),
+		nil, model.Blend.String(), nil}
+	if model.Gradient != nil {
+		d.Gradient = model.Gradient.Map(model.outputColor)
+	}
+	for i, shape := range model.Shapes {
+		data, err := json.Marshal(shape)
+		if err != nil {
+			return "", err
+		}
+		d.Shapes = append(d.Shapes, jsonShape{shapeName(shape), model.outputColor(model.Colors[i]), data})
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	return string(data), err
+}
+
+func ParseJSON(data []byte) (*Drawing, error) {
+	var j jsonDrawing
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	blend, ok := ParseBlendMode(j.Blend)
+	if !ok {
+		return nil, fmt.Errorf("invalid blend mode: %q", j.Blend)
+	}
+	if j.Width < 1 || j.Height < 1 || j.Scale <= 0 {
+		return nil, fmt.Errorf("invalid drawing size")
+	}
+	d := &Drawing{j.Width, j.Height, j.Scale, j.Background, j.Gradient, blend, nil, nil}
+	for _, s := range j.Shapes {
+		var shape Shape
+		switch s.Type {
+		case "triangle":
+			shape = &Triangle{}
+		case "rectangle":
+			shape = &Rectangle{}
+		case "ellipse", "circle":
+			shape = &Ellipse{}
+		case "rotatedrectangle":
+			shape = &RotatedRectangle{}
+		case "quadratic":
+			shape = &Quadratic{}
+		case "rotatedellipse":
+			shape = &RotatedEllipse{}
+		case "polygon":
+			shape = &Polygon{}
+		default:
+			return nil, fmt.Errorf("invalid shape type: %q", s.Type)
+		}
+		if err := json.Unmarshal(s.Shape, shape); err != nil {
+			return nil, err
+		}
+		if p, ok := shape.(*Polygon); ok && (p.Order < 3 || len(p.X) != p.Order || len(p.Y) != p.Order) {
+			return nil, fmt.Errorf("invalid polygon")
+		}
+		d.Shapes = append(d.Shapes, shape)
+		d.Colors = append(d.Colors, s.Color)
+	}
+	return d, nil
+}
+
+var svgTransformPattern = regexp.MustCompile(`(\w+)\(([^)]*)\)`)
+
+// svgNumbers parses numbers separated by spaces or commas.
+func svgNumbers(s string) ([]float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t' || r == '\n'
+	})
+	result := make([]float64, len(fields))
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = x
+	}
+	return result, nil
+}
+
+// svgTransform parses a transform attribute into its functions' arguments.
+func svgTransform(s string) map[string][]float64 {
+	result := make(map[string][]float64)
+	for _, m := range svgTransformPattern.FindAllStringSubmatch(s, -1) {
+		if numbers, err := svgNumbers(m[2]); err == nil {
+			result[m[1]] = numbers
+		}
+	}
+	return result
+}
+
+// svgColor reads a color from a fill or stroke attribute and its opacity.
+func svgColor(attrs map[string]string, paint string) Color {
+	c := MakeHexColor(attrs[paint])
+	if op, err := strconv.ParseFloat(attrs[paint+"-opacity"], 64); err == nil {
+		c.A = int(math.Floor(op*255 + 0.5))
+	}
+	return c
+}
+
+// ParseSVG reads the output of Model.SVG, including with SVGOptions, and
+// AnimatedSVG. Other SVG files, including CompactSVG output, are not
+// supported.
+func ParseSVG(data []byte) (*Drawing, error) {
+	d := &Drawing{}
+	var gradient []float64
+	var stops []Color
+	var indexes []int
+	var rotation map[string][]float64
+	inShapes := false
+	depth, shapesDepth, rotationDepth := 0, 0, 0
+	number := func(attrs map[string]string, name string) float64 {
+		x, _ := strconv.ParseFloat(attrs[name], 64)
+		return x
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tok.(xml.EndElement); ok {
+			if inShapes && depth == shapesDepth {
+				inShapes = false
+			}
+			if rotation != nil && depth == rotationDepth {
+				rotation = nil
+			}
+			depth--
+			continue
+		}
+		e, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		depth++
+		attrs := make(map[string]string)
+		for _, a := range e.Attr {
+			attrs[a.Name.Local] = a.Value
+		}
+		switch e.Name.Local {
+		case "svg":
+			d.Width, _ = strconv.Atoi(attrs["width"])
+			d.Height, _ = strconv.Atoi(attrs["height"])
+			continue
+		case "linearGradient":
+			gradient = []float64{number(attrs, "x1"), number(attrs, "y1"), number(attrs, "x2"), number(attrs, "y2")}
+			continue
+		case "stop":
+			stops = append(stops, MakeHexColor(attrs["stop-color"]))
+			continue
+		case "g":
+			t := svgTransform(attrs["transform"])
+			if scale, ok := t["scale"]; ok && !inShapes && len(scale) > 0 {
+				d.Scale = scale[0]
+				inShapes = true
+				shapesDepth = depth
+			} else if inShapes && t["translate"] != nil {
+				rotation = t
+				rotationDepth = depth
+			}
+			continue
+		}
+		if !inShapes {
+			if e.Name.Local == "rect" && d.Scale == 0 {
+				if attrs["fill"] == "url(#bg)" && len(gradient) == 4 && len(stops) == 2 {
+					d.Gradient = &Gradient{gradient[0], gradient[1], gradient[2], gradient[3], stops[0], stops[1]}
+					d.Background = stops[0]
+				} else {
+					d.Background = svgColor(attrs, "fill")
+				}
+			}
+			continue
+		}
+
+		var shape Shape
+		paint := "fill"
+		switch e.Name.Local {
+		case "polygon":
+			points, err := svgNumbers(attrs["points"])
+			if err != nil || len(points) < 6 || len(points)%2 != 0 {
+				return nil, fmt.Errorf("invalid polygon: %q", attrs["points"])
+			}
+			if strings.Contains(strings.TrimSpace(attrs["points"]), " ") {
+				// triangles separate points with spaces and use integers
+				p := make([]int, 6)
+				for i := range p {
+					p[i] = int(points[i])
+				}
+				shape = &Triangle{nil, p[0], p[1], p[2], p[3], p[4], p[5]}
+			} else {
+				n := len(points) / 2
+				p := &Polygon{nil, n, false, make([]float64, n), make([]float64, n)}
+				for i := 0; i < n; i++ {
+					p.X[i], p.Y[i] = points[2*i], points[2*i+1]
+				}
+				shape = p
+			}
+		case "rect":
+			if rotation != nil {
+				t, r, s := rotation["translate"], rotation["rotate"], rotation["scale"]
+				if len(t) < 2 || len(r) < 1 || len(s) < 2 {
+					return nil, fmt.Errorf("invalid rotated rectangle")
+				}
+				shape = &RotatedRectangle{nil, int(t[0]), int(t[1]), int(s[0]), int(s[1]), int(r[0])}
+			} else {
+				x, y := int(number(attrs, "x")), int(number(attrs, "y"))
+				w, h := int(number(attrs, "width")), int(number(attrs, "height"))
+				shape = &Rectangle{nil, x, y, x + w - 1, y + h - 1}
+			}
+		case "ellipse":
+			if rotation != nil {
+				t, r, s := rotation["translate"], rotation["rotate"], rotation["scale"]
+				if len(t) < 2 || len(r) < 1 || len(s) < 2 {
+					return nil, fmt.Errorf("invalid rotated ellipse")
+				}
+				shape = &RotatedEllipse{nil, t[0], t[1], s[0], s[1], r[0]}
+			} else {
+				rx, ry := int(number(attrs, "rx")), int(number(attrs, "ry"))
+				shape = &Ellipse{nil, int(number(attrs, "cx")), int(number(attrs, "cy")), rx, ry, rx == ry}
+			}
+		case "path":
+			p, err := svgNumbers(strings.NewReplacer("M", " ", "Q", " ").Replace(attrs["d"]))
+			if err != nil || len(p) != 6 {
+				return nil, fmt.Errorf("invalid path: %q", attrs["d"])
+			}
+			shape = &Quadratic{nil, p[0], p[1], p[2], p[3], p[4], p[5], number(attrs, "stroke-width")}
+			paint = "stroke"
+		default:
+			continue
+		}
+		if strings.Contains(attrs["style"], "mix-blend-mode:") {
+			name := attrs["style"][strings.Index(attrs["style"], ":")+1:]
+			name = strings.TrimSpace(strings.TrimSuffix(name, ";"))
+			if name == "plus-lighter" {
+				name = "add"
+			}
+			d.Blend, _ = ParseBlendMode(name)
+		}
+		if index, err := strconv.Atoi(attrs["data-index"]); err == nil {
+			indexes = append(indexes, index)
+		}
+		d.Shapes = append(d.Shapes, shape)
+		d.Colors = append(d.Colors, svgColor(attrs, paint))
+	}
+	if d.Width < 1 || d.Height < 1 || d.Scale <= 0 {
+		return nil, fmt.Errorf("not a primitive svg")
+	}
+	if g := d.Gradient; g != nil {
+		g.X1, g.Y1, g.X2, g.Y2 = g.X1/d.Scale, g.Y1/d.Scale, g.X2/d.Scale, g.Y2/d.Scale
+	}
+	// shapes sorted by importance are put back in the order they were added
+	if len(indexes) == len(d.Shapes) {
+		order := make([]int, len(indexes))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return indexes[order[i]] < indexes[order[j]] })
+		shapes := make([]Shape, len(order))
+		colors := make([]Color, len(order))
+		for i, j := range order {
+			shapes[i], colors[i] = d.Shapes[j], d.Colors[j]
+		}
+		d.Shapes, d.Colors = shapes, colors
+	}
+	return d, nil
+}
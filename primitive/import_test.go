@@ -0,0 +1,124 @@
+package primitive
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+var cachedImportModel *Model
+
+// importModel returns a model with every type of shape, shared by tests
+// that don't modify it.
+func importModel(t *testing.T) *Model {
+	if cachedImportModel != nil {
+		return cachedImportModel
+	}
+	im, err := LoadImage("../examples/lenna.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im = resize.Thumbnail(64, 64, im, resize.Bilinear)
+	model := NewModel(im, MakeColor(AverageImageColor(im)), 256, 1)
+	model.Seed(1)
+	for i := 0; i < 30; i++ {
+		model.Step(ShapeTypeAny, 128, 0)
+	}
+	cachedImportModel = model
+	return model
+}
+
+// sameDrawing compares the shapes and colors of a model and a drawing.
+func sameDrawing(t *testing.T, model *Model, d *Drawing) {
+	if len(d.Shapes) != len(model.Shapes) {
+		t.Fatalf("got %d shapes, want %d", len(d.Shapes), len(model.Shapes))
+	}
+	for i, shape := range d.Shapes {
+		if got, want := shapeName(shape), shapeName(model.Shapes[i]); got != want {
+			t.Errorf("shape %d: got %s, want %s", i, got, want)
+		}
+		if d.Colors[i] != model.Colors[i] {
+			t.Errorf("shape %d: got color %v, want %v", i, d.Colors[i], model.Colors[i])
+		}
+	}
+}
+
+func TestParseSVG(t *testing.T) {
+	model := importModel(t)
+	d, err := ParseSVG([]byte(model.SVG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameDrawing(t, model, d)
+	if d.Width != model.Sw || d.Height != model.Sh || d.Scale != model.Scale {
+		t.Errorf("got size %dx%d scale %v", d.Width, d.Height, d.Scale)
+	}
+	// rendering the parsed drawing at the same size gives the same image
+	got := d.Model(256).Context.Image().(*image.RGBA)
+	want := model.Context.Image().(*image.RGBA)
+	if score := differenceFull(got, want); score > 0.001 {
+		t.Errorf("rendered drawing differs by %v", score)
+	}
+
+	// sorted shapes are put back in order
+	sorted := model.SVGWithOptions(SVGOptions{Metadata: true, SortByImportance: true})
+	d, err = ParseSVG([]byte(sorted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameDrawing(t, model, d)
+}
+
+func TestParseJSON(t *testing.T) {
+	model := importModel(t)
+	data, err := model.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameDrawing(t, model, d)
+	got := d.Model(256).Context.Image().(*image.RGBA)
+	want := model.Context.Image().(*image.RGBA)
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("rendered drawing differs from the model")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	model := importModel(t)
+	d, err := ParseJSON([]byte(mustJSON(t, model)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// replaying onto the same target reproduces the score
+	other := NewModel(model.Target, Color{}, 256, 1)
+	if err := other.Replay(d); err != nil {
+		t.Fatal(err)
+	}
+	if other.Score != model.Score {
+		t.Errorf("replayed score %v, want %v", other.Score, model.Score)
+	}
+
+	other.SetBlendMode(BlendMultiply)
+	if err := other.Replay(d); err == nil {
+		t.Error("replayed a drawing with a different blend mode")
+	}
+	other.SetBlendMode(BlendNormal)
+	other.SetGrayscale(&Duotone{Dark: Color{0, 0, 64, 255}, Light: Color{255, 255, 0, 255}})
+	if err := other.Replay(d); err == nil {
+		t.Error("replayed a drawing into a duotone model")
+	}
+}
+
+func mustJSON(t *testing.T, model *Model) string {
+	data, err := model.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,133 @@
+package primitive
+
+import (
+	"image"
+	"math"
+)
+
+// rowSums holds per-row prefix sums of the red, green and blue channels of
+// an image, so the sum over any scanline can be read in constant time.
+type rowSums struct {
+	W, H int
+	Sums []int32
+}
+
+func newRowSums(im *image.RGBA) *rowSums {
+	size := im.Bounds().Size()
+	rs := &rowSums{size.X, size.Y, make([]int32, (size.X+1)*size.Y*3)}
+	rs.update(im)
+	return rs
+}
+
+func (rs *rowSums) update(im *image.RGBA) {
+	for y := 0; y < rs.H; y++ {
+		i := im.PixOffset(0, y)
+		j := y * (rs.W + 1) * 3
+		var r, g, b int32
+		rs.Sums[j+0] = 0
+		rs.Sums[j+1] = 0
+		rs.Sums[j+2] = 0
+		for x := 0; x < rs.W; x++ {
+			r += int32(im.Pix[i+0])
+			g += int32(im.Pix[i+1])
+			b += int32(im.Pix[i+2])
+			i += 4
+			j += 3
+			rs.Sums[j+0] = r
+			rs.Sums[j+1] = g
+			rs.Sums[j+2] = b
+		}
+	}
+}
+
+func (rs *rowSums) line(line Scanline) (r, g, b int64) {
+	i := (line.Y*(rs.W+1) + line.X1) * 3
+	j := i + (line.X2-line.X1+1)*3
+	s := rs.Sums
+	return int64(s[j] - s[i]), int64(s[j+1] - s[i+1]), int64(s[j+2] - s[i+2])
+}
+
+// mean returns the average color under the lines with the given alpha.
+func (rs *rowSums) mean(lines []Scanline, alpha int) Color {
+	var rsum, gsum, bsum, count int64
+	for _, line := range lines {
+		r, g, b := rs.line(line)
+		rsum += r
+		gsum += g
+		bsum += b
+		count += int64(line.X2 - line.X1 + 1)
+	}
+	if count == 0 {
+		return Color{}
+	}
+	return Color{int(rsum / count), int(gsum / count), int(bsum / count), alpha}
+}
+
+// computeColorSums returns the same color as computeColor, but reads whole
+// scanlines from prefix sums instead of visiting every pixel. The per pixel
+// term (t-c)*a + c*0x101 is linear, so it can be summed per line.
+func computeColorSums(target, current *rowSums, lines []Scanline, alpha int) Color {
+	var rsum, gsum, bsum, count int64
+	a := int64(0x101 * 255 / alpha)
+	for _, line := range lines {
+		tr, tg, tb := target.line(line)
+		cr, cg, cb := current.line(line)
+		rsum += (tr-cr)*a + cr*0x101
+		gsum += (tg-cg)*a + cg*0x101
+		bsum += (tb-cb)*a + cb*0x101
+		count += int64(line.X2 - line.X1 + 1)
+	}
+	if count == 0 {
+		return Color{}
+	}
+	r := clampInt(int(rsum/count)>>8, 0, 255)
+	g := clampInt(int(gsum/count)>>8, 0, 255)
+	b := clampInt(int(bsum/count)>>8, 0, 255)
+	return Color{r, g, b, alpha}
+}
+
+// energyNormal returns the score that differencePartial would report after
+// drawLines draws c onto current, computing each blended pixel on the fly
+// instead of writing it to a buffer first.
+func energyNormal(target, current *image.RGBA, c Color, lines []Scanline, score float64) float64 {
+	const m = 0xffff
+	size := target.Bounds().Size()
+	w, h := size.X, size.Y
+	total := uint64(math.Pow(score*255, 2) * float64(w*h*4))
+	sr, sg, sb, sa := c.NRGBA().RGBA()
+	for _, line := range lines {
+		ma := line.Alpha
+		a := (m - sa*ma/m) * 0x101
+		r := sr * ma
+		g := sg * ma
+		b := sb * ma
+		q := sa * ma
+		i := target.PixOffset(line.X1, line.Y)
+		for x := line.X1; x <= line.X2; x++ {
+			tr := int(target.Pix[i])
+			tg := int(target.Pix[i+1])
+			tb := int(target.Pix[i+2])
+			ta := int(target.Pix[i+3])
+			br := uint32(current.Pix[i])
+			bg := uint32(current.Pix[i+1])
+			bb := uint32(current.Pix[i+2])
+			ba := uint32(current.Pix[i+3])
+			i += 4
+			ar := int(uint8((br*a + r) / m >> 8))
+			ag := int(uint8((bg*a + g) / m >> 8))
+			ab := int(uint8((bb*a + b) / m >> 8))
+			aa := int(uint8((ba*a + q) / m >> 8))
+			dr1 := tr - int(br)
+			dg1 := tg - int(bg)
+			db1 := tb - int(bb)
+			da1 := ta - int(ba)
+			dr2 := tr - ar
+			dg2 := tg - ag
+			db2 := tb - ab
+			da2 := ta - aa
+			total -= uint64(dr1*dr1 + dg1*dg1 + db1*db1 + da1*da1)
+			total += uint64(dr2*dr2 + dg2*dg2 + db2*db2 + da2*da2)
+		}
+	}
+	return math.Sqrt(float64(total)/float64(w*h*4)) / 255
+}
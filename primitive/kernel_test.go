@@ -0,0 +1,174 @@
+package primitive
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+var kernelShapeTypes = []ShapeType{
+	ShapeTypeTriangle,
+	ShapeTypeRectangle,
+	ShapeTypeEllipse,
+	ShapeTypeCircle,
+	ShapeTypeRotatedRectangle,
+	ShapeTypeQuadratic,
+	ShapeTypeRotatedEllipse,
+	ShapeTypePolygon,
+}
+
+func kernelModel(tb testing.TB) *Model {
+	im, err := LoadImage("../examples/monalisa.png")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	im = resize.Thumbnail(256, 256, im, resize.Bilinear)
+	model := NewModel(im, MakeColor(AverageImageColor(im)), 256, 1)
+	for i := 0; i < 10; i++ {
+		model.Step(ShapeTypeAny, 128, 0)
+	}
+	return model
+}
+
+func kernelWorker(tb testing.TB) *Worker {
+	model := kernelModel(tb)
+	worker := NewWorker(model.Target)
+	worker.Rnd = rand.New(rand.NewSource(1))
+	worker.Init(model.Current, model.Score)
+	return worker
+}
+
+// bufferedEnergy is Energy without the fused kernel.
+func bufferedEnergy(worker *Worker, shape Shape, alpha int) float64 {
+	lines := shape.Rasterize()
+	color := computeColor(worker.Target, worker.Current, lines, alpha)
+	copyLines(worker.Buffer, worker.Current, lines)
+	drawLines(worker.Buffer, color, lines)
+	return differencePartial(worker.Target, worker.Current, worker.Buffer, worker.Score, lines)
+}
+
+func TestFusedEnergy(t *testing.T) {
+	worker := kernelWorker(t)
+	for _, st := range kernelShapeTypes {
+		for i := 0; i < 100; i++ {
+			state := worker.RandomState(st, 128)
+			want := bufferedEnergy(worker, state.Shape, 128)
+			got := worker.Energy(state.Shape, 128)
+			if got != want {
+				t.Fatalf("shape type %d: fused energy %v, buffered energy %v", st, got, want)
+			}
+		}
+	}
+}
+
+// TestFusedEnergyGray checks the fused kernel against the grayscale path
+// for a model that was converted to grayscale after its workers were made.
+func TestFusedEnergyGray(t *testing.T) {
+	model := kernelModel(t)
+	model.SetPyramid(2)
+	model.SetGrayscale(nil)
+	worker := model.Workers[0]
+	coarse := downsampleRGBA(model.Current, 2)
+	worker.Coarse.Init(coarse, differenceFull(worker.Coarse.Target, coarse))
+	worker.Init(model.Current, model.Score)
+	for _, worker := range []*Worker{worker, worker.Coarse} {
+		worker.Rnd = rand.New(rand.NewSource(1))
+		if !worker.fused() {
+			t.Fatal("grayscale worker doesn't use the fused kernel")
+		}
+		for _, st := range kernelShapeTypes {
+			for i := 0; i < 100; i++ {
+				state := worker.RandomState(st, 128)
+				lines := state.Shape.Rasterize()
+				color := computeColorGray(worker.Target, worker.Current, lines, 128)
+				copyLines(worker.Buffer, worker.Current, lines)
+				drawLinesGray(worker.Buffer, color, lines)
+				want := differencePartialGray(worker.Target, worker.Current, worker.Buffer, worker.Score, lines)
+				got := worker.Energy(state.Shape, 128)
+				if math.Abs(got-want) > 1e-9 {
+					t.Fatalf("shape type %d: fused energy %v, grayscale energy %v", st, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestComputeColorSums(t *testing.T) {
+	worker := kernelWorker(t)
+	lines := []Scanline{{3, 0, 255, 0xffff}, {4, 10, 10, 0xffff}, {200, 17, 90, 0xffff}}
+	for _, alpha := range []int{1, 64, 128, 255} {
+		want := computeColor(worker.Target, worker.Current, lines, alpha)
+		got := computeColorSums(worker.TargetSums, worker.CurrentSums, lines, alpha)
+		if got != want {
+			t.Fatalf("alpha %d: got %v, want %v", alpha, got, want)
+		}
+	}
+}
+
+func benchmarkEnergy(b *testing.B, energy func(*Worker, Shape, int) float64) {
+	worker := kernelWorker(b)
+	for _, st := range kernelShapeTypes {
+		var shapes []Shape
+		for i := 0; i < 64; i++ {
+			shapes = append(shapes, worker.RandomState(st, 128).Shape)
+		}
+		b.Run(fmt.Sprintf("type=%d", st), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				energy(worker, shapes[i%len(shapes)], 128)
+			}
+		})
+	}
+}
+
+func BenchmarkEnergyBuffered(b *testing.B) {
+	benchmarkEnergy(b, bufferedEnergy)
+}
+
+func BenchmarkEnergyFused(b *testing.B) {
+	benchmarkEnergy(b, (*Worker).Energy)
+}
+
+func BenchmarkRowSums(b *testing.B) {
+	worker := kernelWorker(b)
+	rs := newRowSums(image.NewRGBA(worker.Current.Bounds()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rs.update(worker.Current)
+	}
+}
+
+func TestColorSource(t *testing.T) {
+	im, err := LoadImage("../examples/monalisa.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im = resize.Thumbnail(64, 64, im, resize.Bilinear)
+	// left half red, right half blue
+	size := im.Bounds().Size()
+	source := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	source.Pix = []uint8{255, 0, 0, 255, 0, 0, 255, 255}
+	model := NewModel(im, MakeColor(AverageImageColor(im)), 64, 1)
+	model.SetColorSource(source)
+	model.SetPyramid(2)
+	model.Seed(1)
+	for i := 0; i < 5; i++ {
+		model.Step(ShapeTypeRectangle, 128, 0)
+	}
+	for i, c := range model.Colors {
+		if c.G != 0 || c.R+c.B < 250 || c.A != 128 {
+			t.Errorf("shape %d has color %v, want a mix of red and blue", i, c)
+		}
+	}
+	lines := []Scanline{{0, 0, size.X - 1, 0xffff}}
+	if c := model.Source.mean(lines, 255); c.R < 100 || c.B < 100 {
+		t.Errorf("mean over a row is %v, want red and blue", c)
+	}
+	model.SetGrayscale(nil)
+	if c := model.Source.mean(lines, 255); c.R != c.G || c.G != c.B {
+		t.Errorf("grayscale mean is %v", c)
+	}
+}
@@ -3,15 +3,23 @@ package primitive
 import (
 	"fmt"
 	"image"
+	"image/draw"
+	"math/rand"
+	"sort"
 	"strings"
 
 	"github.com/fogleman/gg"
+	xdraw "golang.org/x/image/draw"
 )
 
 type Model struct {
 	Sw, Sh     int
 	Scale      float64
 	Background Color
+	Gradient   *Gradient
+	BaseImage  image.Image
+	BaseHref   string
+	Duotone    *Duotone
 	Target     *image.RGBA
 	Current    *image.RGBA
 	Context    *gg.Context
@@ -20,23 +28,20 @@ type Model struct {
 	Colors     []Color
 	Scores     []float64
 	Workers    []*Worker
+	Pyramid    int
+	Renderer   Renderer
+	startScore float64
+	coarse     *image.RGBA
+	source     *image.RGBA
+	mask       *gg.Context
+	output     *Worker
+	colorConfig
 }
 
 func NewModel(target image.Image, background Color, size, numWorkers int) *Model {
 	w := target.Bounds().Size().X
 	h := target.Bounds().Size().Y
-	aspect := float64(w) / float64(h)
-	var sw, sh int
-	var scale float64
-	if aspect >= 1 {
-		sw = size
-		sh = int(float64(size) / aspect)
-		scale = float64(size) / float64(w)
-	} else {
-		sw = int(float64(size) * aspect)
-		sh = size
-		scale = float64(size) / float64(h)
-	}
+	sw, sh, scale := outputSize(w, h, size)
 
 	model := &Model{}
 	model.Sw = sw
@@ -46,6 +51,7 @@ func NewModel(target image.Image, background Color, size, numWorkers int) *Model
 	model.Target = imageToRGBA(target)
 	model.Current = uniformRGBA(target.Bounds(), background.NRGBA())
 	model.Score = differenceFull(model.Target, model.Current)
+	model.startScore = model.Score
 	model.Context = model.newContext()
 	for i := 0; i < numWorkers; i++ {
 		worker := NewWorker(model.Target)
@@ -54,12 +60,200 @@ func NewModel(target image.Image, background Color, size, numWorkers int) *Model
 	return model
 }
 
+// outputSize fits a w x h image into a size x size output.
+func outputSize(w, h, size int) (sw, sh int, scale float64) {
+	aspect := float64(w) / float64(h)
+	if aspect >= 1 {
+		sw = size
+		sh = int(float64(size) / aspect)
+		scale = float64(size) / float64(w)
+	} else {
+		sw = int(float64(size) * aspect)
+		sh = size
+		scale = float64(size) / float64(h)
+	}
+	return
+}
+
+func (model *Model) SetBlendMode(mode BlendMode) {
+	model.Blend = mode
+	model.updateWorkers()
+}
+
+func (model *Model) SetPalette(palette []Color) {
+	if model.Gray {
+		palette = grayPalette(palette)
+	}
+	model.Palette = palette
+	model.updateWorkers()
+}
+
+// SetColorSource colors each shape with the average color of im under it
+// instead of the color that best matches the target, so the shapes follow
+// the target while the colors come from another image. The image is
+// stretched to the target size.
+func (model *Model) SetColorSource(im image.Image) {
+	model.source = nil
+	model.Source = nil
+	if im != nil {
+		size := model.Target.Bounds().Size()
+		model.source = image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+		xdraw.BiLinear.Scale(model.source, model.source.Rect, im, im.Bounds(), xdraw.Src, nil)
+		if model.Gray {
+			grayRGBA(model.source)
+		}
+		model.Source = newRowSums(model.source)
+	}
+	model.updateWorkers()
+}
+
+// SetGrayscale converts the model to operate on luminance only. If duotone
+// is not nil, output colors are mapped onto its ramp.
+func (model *Model) SetGrayscale(duotone *Duotone) {
+	model.Gray = true
+	model.Duotone = duotone
+	model.Palette = grayPalette(model.Palette)
+	model.Background = grayColor(model.Background)
+	grayRGBA(model.Target)
+	if model.coarse != nil {
+		grayRGBA(model.coarse)
+	}
+	// the workers share the grayed targets but sum them separately
+	for _, worker := range model.Workers {
+		worker.TargetSums.update(worker.Target)
+		if worker.Coarse != nil {
+			worker.Coarse.TargetSums.update(worker.Coarse.Target)
+		}
+	}
+	if model.source != nil {
+		grayRGBA(model.source)
+		model.Source = newRowSums(model.source)
+	}
+	if model.Gradient != nil {
+		size := model.Target.Bounds().Size()
+		model.Gradient = model.Gradient.Map(grayColor)
+		model.setCanvas(model.Gradient.Image(size.X, size.Y))
+	} else if model.BaseImage != nil {
+		size := model.Target.Bounds().Size()
+		model.setCanvas(model.baseImage(size.X, size.Y))
+	} else {
+		model.setCanvas(uniformRGBA(model.Target.Bounds(), model.Background.NRGBA()))
+	}
+	model.updateWorkers()
+}
+
+// SetGradient replaces the uniform background with a linear gradient.
+func (model *Model) SetGradient(gradient *Gradient) {
+	model.Gradient = gradient
+	model.BaseImage = nil
+	model.Background = gradient.Color1
+	size := model.Target.Bounds().Size()
+	model.setCanvas(gradient.Image(size.X, size.Y))
+}
+
+func (model *Model) setCanvas(current *image.RGBA) {
+	model.Current = current
+	model.Score = differenceFull(model.Target, model.Current)
+	model.startScore = model.Score
+	model.Context = model.newContext()
+}
+
+// SetPyramid makes workers search for shapes on a copy of the target
+// downsampled by factor, promoting only the best one to full resolution
+// for a final hill climb. A factor of 1 or less disables it.
+func (model *Model) SetPyramid(factor int) {
+	model.Pyramid = factor
+	model.coarse = nil
+	if factor > 1 {
+		model.coarse = downsampleRGBA(model.Target, factor)
+	}
+	for _, worker := range model.Workers {
+		worker.Coarse = nil
+		if model.coarse != nil {
+			worker.Coarse = NewWorker(model.coarse)
+			worker.Coarse.Rnd = worker.Rnd
+			worker.CoarseScale = float64(factor)
+		}
+	}
+	model.updateWorkers()
+}
+
+// Seed makes the search repeatable by giving each worker its own random
+// source derived from seed.
+func (model *Model) Seed(seed int64) {
+	for i, worker := range model.Workers {
+		worker.Rnd = rand.New(rand.NewSource(seed + int64(i)))
+		if worker.Coarse != nil {
+			worker.Coarse.Rnd = worker.Rnd
+		}
+	}
+}
+
+func (model *Model) updateWorkers() {
+	var coarseSource *rowSums
+	if model.source != nil && model.coarse != nil {
+		coarseSource = newRowSums(downsampleRGBA(model.source, model.Pyramid))
+	}
+	for _, worker := range model.Workers {
+		worker.colorConfig = model.colorConfig
+		if worker.Coarse != nil {
+			worker.Coarse.colorConfig = model.colorConfig
+			worker.Coarse.Source = coarseSource
+		}
+	}
+}
+
+// PaletteCounts returns the number of shapes drawn with each palette color.
+func (model *Model) PaletteCounts() []int {
+	counts := make([]int, len(model.Palette))
+	for _, c := range model.Colors {
+		for i, p := range model.Palette {
+			if c.R == p.R && c.G == p.G && c.B == p.B {
+				counts[i]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// AlphaCounts returns a histogram of shape alpha values with the given
+// number of equally sized bins.
+func (model *Model) AlphaCounts(bins int) []int {
+	counts := make([]int, bins)
+	for _, c := range model.Colors {
+		counts[c.A*bins/256]++
+	}
+	return counts
+}
+
 func (model *Model) newContext() *gg.Context {
-	dc := gg.NewContext(model.Sw, model.Sh)
+	dc := model.contextFor(image.NewRGBA(image.Rect(0, 0, model.Sw, model.Sh)))
+	bg := model.outputColor(model.Background)
+	dc.SetColor(bg.NRGBA())
+	dc.Clear()
+	if model.BaseImage != nil {
+		im := dc.Image().(*image.RGBA)
+		draw.Draw(im, im.Rect, model.outputBase(), image.ZP, draw.Src)
+	} else if model.Gradient != nil && model.Renderer == RendererNative {
+		model.drawGradientNative(dc.Image().(*image.RGBA))
+	} else if model.Gradient != nil {
+		dc.Push()
+		dc.Identity()
+		dc.SetFillStyle(model.Gradient.Map(model.outputColor).pattern(model.Scale))
+		dc.DrawRectangle(0, 0, float64(model.Sw), float64(model.Sh))
+		dc.Fill()
+		dc.Pop()
+	}
+	return dc
+}
+
+// contextFor wraps an output sized image in a context with the model's
+// shape coordinate transform.
+func (model *Model) contextFor(im *image.RGBA) *gg.Context {
+	dc := gg.NewContextForRGBA(im)
 	dc.Scale(model.Scale, model.Scale)
 	dc.Translate(0.5, 0.5)
-	dc.SetColor(model.Background.NRGBA())
-	dc.Clear()
 	return dc
 }
 
@@ -69,10 +263,7 @@ func (model *Model) Frames(scoreDelta float64) []image.Image {
 	result = append(result, imageToRGBA(dc.Image()))
 	previous := 10.0
 	for i, shape := range model.Shapes {
-		c := model.Colors[i]
-		dc.SetRGBA255(c.R, c.G, c.B, c.A)
-		shape.Draw(dc, model.Scale)
-		dc.Fill()
+		model.drawShape(dc, shape, model.Colors[i])
 		score := model.Scores[i]
 		delta := previous - score
 		if delta >= scoreDelta {
@@ -84,42 +275,179 @@ func (model *Model) Frames(scoreDelta float64) []image.Image {
 }
 
 func (model *Model) SVG() string {
-	bg := model.Background
+	return model.SVGWithOptions(SVGOptions{})
+}
+
+// SVGOptions adds per-shape information to SVG output for post-processing.
+type SVGOptions struct {
+	// Metadata adds data-index, data-type and data-delta attributes to each
+	// shape with its position in Shapes, its type and how much it improved
+	// the score.
+	Metadata bool
+	// SortByImportance writes the shapes that improved the score most
+	// first. This changes the paint order, so the image differs from the
+	// unsorted output; data-index keeps the original order.
+	SortByImportance bool
+}
+
+func (model *Model) SVGWithOptions(opt SVGOptions) string {
+	order := make([]int, len(model.Shapes))
+	for i := range order {
+		order[i] = i
+	}
+	deltas := model.ScoreDeltas()
+	if opt.SortByImportance {
+		sort.SliceStable(order, func(i, j int) bool {
+			return deltas[order[i]] > deltas[order[j]]
+		})
+	}
 	var lines []string
-	lines = append(lines, fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\" width=\"%d\" height=\"%d\">", model.Sw, model.Sh))
-	lines = append(lines, fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\" />", model.Sw, model.Sh, bg.R, bg.G, bg.B))
+	lines = append(lines, model.svgHeader())
+	lines = append(lines, model.backgroundSVG()...)
 	lines = append(lines, fmt.Sprintf("<g transform=\"scale(%f) translate(0.5 0.5)\">", model.Scale))
-	for i, shape := range model.Shapes {
-		c := model.Colors[i]
-		attrs := "fill=\"#%02x%02x%02x\" fill-opacity=\"%f\""
-		attrs = fmt.Sprintf(attrs, c.R, c.G, c.B, float64(c.A)/255)
-		lines = append(lines, shape.SVG(attrs))
+	for _, i := range order {
+		if opt.Metadata {
+			attrs := fmt.Sprintf("data-index=\"%d\" data-type=\"%s\" data-delta=\"%.6g\" ",
+				i, shapeName(model.Shapes[i]), deltas[i])
+			lines = append(lines, model.shapeSVGAttrs(i, attrs))
+		} else {
+			lines = append(lines, model.shapeSVG(i))
+		}
 	}
 	lines = append(lines, "</g>")
 	lines = append(lines, "</svg>")
 	return strings.Join(lines, "\n")
 }
 
+// ScoreDeltas returns how much each shape improved the score when it was
+// added.
+func (model *Model) ScoreDeltas() []float64 {
+	deltas := make([]float64, len(model.Scores))
+	previous := model.startScore
+	for i, score := range model.Scores {
+		deltas[i] = previous - score
+		previous = score
+	}
+	return deltas
+}
+
+func (model *Model) svgHeader() string {
+	return fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\" width=\"%d\" height=\"%d\">", model.Sw, model.Sh)
+}
+
+func (model *Model) backgroundSVG() []string {
+	var lines []string
+	bg := model.outputColor(model.Background)
+	if model.BaseImage != nil {
+		lines = append(lines, model.baseSVG())
+	} else if model.Gradient != nil {
+		g := model.Gradient.Map(model.outputColor)
+		lines = append(lines, "<defs>"+g.SVG("bg", model.Scale)+"</defs>")
+		lines = append(lines, fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"url(#bg)\" />", model.Sw, model.Sh))
+	} else if bg.A == 255 {
+		lines = append(lines, fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\" />", model.Sw, model.Sh, bg.R, bg.G, bg.B))
+	} else if bg.A > 0 {
+		lines = append(lines, fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\" fill-opacity=\"%f\" />", model.Sw, model.Sh, bg.R, bg.G, bg.B, float64(bg.A)/255))
+	}
+	return lines
+}
+
+func (model *Model) shapeSVG(i int) string {
+	return model.shapeSVGAttrs(i, "")
+}
+
+// shapeSVGAttrs writes shape i with extra attributes before its fill.
+func (model *Model) shapeSVGAttrs(i int, extra string) string {
+	c := model.outputColor(model.Colors[i])
+	attrs := extra + "fill=\"#%02x%02x%02x\" fill-opacity=\"%f\""
+	attrs = fmt.Sprintf(attrs, c.R, c.G, c.B, float64(c.A)/255)
+	if model.Blend != BlendNormal {
+		attrs += fmt.Sprintf(" style=\"mix-blend-mode:%s\"", model.Blend.CSS())
+	}
+	return model.Shapes[i].SVG(attrs)
+}
+
 func (model *Model) Add(shape Shape, alpha int) {
-	before := copyRGBA(model.Current)
 	lines := shape.Rasterize()
-	color := computeColor(model.Target, model.Current, lines, alpha)
-	drawLines(model.Current, color, lines)
-	score := differencePartial(model.Target, before, model.Current, model.Score, lines)
+	var buffer *image.RGBA
+	if len(model.Palette) > 0 {
+		buffer = copyRGBA(model.Current)
+	}
+	color := model.computeColor(model.Target, model.Current, buffer, lines, alpha, model.Score)
+	model.add(shape, lines, color)
+}
+
+// AddColored adds a shape with a given color instead of the optimal one.
+func (model *Model) AddColored(shape Shape, color Color) {
+	model.add(shape, shape.Rasterize(), color)
+}
+
+func (model *Model) add(shape Shape, lines []Scanline, color Color) {
+	before := copyRGBA(model.Current)
+	model.drawLines(model.Current, color, lines)
+	score := model.difference(model.Target, before, model.Current, model.Score, lines)
 
 	model.Score = score
 	model.Shapes = append(model.Shapes, shape)
 	model.Colors = append(model.Colors, color)
 	model.Scores = append(model.Scores, score)
 
-	model.Context.SetRGBA255(color.R, color.G, color.B, color.A)
-	shape.Draw(model.Context, model.Scale)
+	model.drawShape(model.Context, shape, color)
+}
+
+func (model *Model) outputColor(c Color) Color {
+	if model.Duotone != nil {
+		return model.Duotone.Map(c)
+	}
+	return c
+}
+
+func (model *Model) drawShape(dc *gg.Context, shape Shape, c Color) {
+	c = model.outputColor(c)
+	if model.Renderer == RendererNative {
+		model.drawShapeNative(dc.Image().(*image.RGBA), shape, c)
+		return
+	}
+	if model.Blend == BlendNormal {
+		dc.SetRGBA255(c.R, c.G, c.B, c.A)
+		shape.Draw(dc, model.Scale)
+		return
+	}
+	// gg only supports source-over compositing, so render the shape's
+	// coverage into a mask and blend it in ourselves
+	if model.mask == nil {
+		model.mask = gg.NewContext(model.Sw, model.Sh)
+		model.mask.Scale(model.Scale, model.Scale)
+		model.mask.Translate(0.5, 0.5)
+	}
+	mask := model.mask
+	mask.SetRGBA(0, 0, 0, 0)
+	mask.Clear()
+	mask.SetRGB(0, 0, 0)
+	shape.Draw(mask, model.Scale)
+	lines := maskLines(mask.Image().(*image.RGBA))
+	drawLinesBlend(dc.Image().(*image.RGBA), c, lines, model.Blend)
 }
 
 func (model *Model) Step(shapeType ShapeType, alpha, repeat int) int {
-	state := model.runWorkers(shapeType, alpha, 1000, 100, 16)
-	// state = HillClimb(state, 1000).(*State)
+	counter, _ := model.StepSpeculative(shapeType, alpha, repeat, 1)
+	return counter
+}
+
+// StepSpeculative adds up to max shapes in one round of the workers. The
+// best state is always added. The other workers' states are then tried in
+// order of energy, skipping any whose bounds overlap a shape placed this
+// round, and are only kept if they still improve the updated canvas.
+// It returns the number of states evaluated and the number of shapes
+// placed, not counting repeats.
+func (model *Model) StepSpeculative(shapeType ShapeType, alpha, repeat, max int) (int, int) {
+	states := model.runWorkers(shapeType, alpha, 1000, 100, 16)
+	state := states[0]
 	model.Add(state.Shape, state.Alpha)
+	var placed []image.Rectangle
+	if max > 1 {
+		placed = append(placed, scanlineBounds(state.Shape.Rasterize()))
+	}
 
 	for i := 0; i < repeat; i++ {
 		state.Worker.Init(model.Current, model.Score)
@@ -130,6 +458,9 @@ func (model *Model) Step(shapeType ShapeType, alpha, repeat int) int {
 			break
 		}
 		model.Add(state.Shape, state.Alpha)
+		if max > 1 {
+			placed = append(placed, scanlineBounds(state.Shape.Rasterize()))
+		}
 	}
 
 	// for _, w := range model.Workers[1:] {
@@ -137,36 +468,88 @@ func (model *Model) Step(shapeType ShapeType, alpha, repeat int) int {
 	// }
 	// SavePNG("heatmap.png", model.Workers[0].Heatmap.Image(0.5))
 
+	counter := model.counter()
+	count := 1
+	for _, state := range states[1:] {
+		if count >= max {
+			break
+		}
+		bounds := scanlineBounds(state.Shape.Rasterize())
+		overlaps := false
+		for _, r := range placed {
+			if r.Overlaps(bounds) {
+				overlaps = true
+				break
+			}
+		}
+		if overlaps {
+			continue
+		}
+		// the color and energy depend on the canvas, so check it again
+		state.Worker.Init(model.Current, model.Score)
+		state.Score = -1
+		if state.Energy() >= model.Score {
+			continue
+		}
+		model.Add(state.Shape, state.Alpha)
+		placed = append(placed, bounds)
+		count++
+	}
+	return counter, count
+}
+
+// StepSearch adds one shape like Step, but searches with n random states,
+// age failed mutations per hill climb and m hill climbs in all instead of
+// the defaults (1000, 100 and 16), for when a rough result is enough.
+func (model *Model) StepSearch(shapeType ShapeType, alpha, n, age, m int) int {
+	state := model.runWorkers(shapeType, alpha, n, age, m)[0]
+	model.Add(state.Shape, state.Alpha)
+	return model.counter()
+}
+
+// counter returns the number of states the workers evaluated in the last
+// round.
+func (model *Model) counter() int {
 	counter := 0
 	for _, worker := range model.Workers {
 		counter += worker.Counter
+		if worker.Coarse != nil {
+			counter += worker.Coarse.Counter
+		}
 	}
 	return counter
 }
 
-func (model *Model) runWorkers(t ShapeType, a, n, age, m int) *State {
+// runWorkers returns the best state found by each worker, best first.
+func (model *Model) runWorkers(t ShapeType, a, n, age, m int) []*State {
 	wn := len(model.Workers)
 	ch := make(chan *State, wn)
 	wm := m / wn
 	if m%wn != 0 {
 		wm++
 	}
+	var coarse *image.RGBA
+	var coarseScore float64
+	if model.coarse != nil {
+		coarse = downsampleRGBA(model.Current, model.Pyramid)
+		coarseScore = differenceFull(model.coarse, coarse)
+	}
 	for i := 0; i < wn; i++ {
 		worker := model.Workers[i]
 		worker.Init(model.Current, model.Score)
+		if worker.Coarse != nil {
+			worker.Coarse.Init(coarse, coarseScore)
+		}
 		go model.runWorker(worker, t, a, n, age, wm, ch)
 	}
-	var bestEnergy float64
-	var bestState *State
-	for i := 0; i < wn; i++ {
-		state := <-ch
-		energy := state.Energy()
-		if i == 0 || energy < bestEnergy {
-			bestEnergy = energy
-			bestState = state
-		}
+	states := make([]*State, wn)
+	for i := range states {
+		states[i] = <-ch
 	}
-	return bestState
+	sort.SliceStable(states, func(i, j int) bool {
+		return states[i].Energy() < states[j].Energy()
+	})
+	return states
 }
 
 func (model *Model) runWorker(worker *Worker, t ShapeType, a, n, age, m int, ch chan *State) {
@@ -0,0 +1,352 @@
+package primitive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// paletteCandidates is the number of palette entries nearest to the optimal
+// color that are evaluated when choosing a shape's color.
+const paletteCandidates = 4
+
+// maxPaletteSize is the largest number of colors ParsePalette extracts.
+const maxPaletteSize = 256
+
+// PaletteSize reports whether a -palette argument asks for colors to be
+// extracted, and how many. That is "kmeans:N", or a plain N unless it is
+// also a 3 or 6 digit hex color, like 112 or 000000.
+func PaletteSize(value string) (int, bool) {
+	if strings.HasPrefix(value, "kmeans:") {
+		n, err := strconv.Atoi(strings.TrimPrefix(value, "kmeans:"))
+		if err != nil {
+			return 0, true
+		}
+		return n, true
+	}
+	if _, err := parseHexColor(value); err == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil
+}
+
+// ParsePalette interprets a -palette argument. It accepts a number of
+// colors to extract from the target image (see PaletteSize), a palette
+// file (.gpl, .ase or one hex color per line), or a comma separated list
+// of hex colors.
+func ParsePalette(value string, target image.Image) ([]Color, error) {
+	if n, ok := PaletteSize(value); ok {
+		if n < 1 || n > maxPaletteSize {
+			return nil, fmt.Errorf("invalid palette size: %q, must be 1 to %d", value, maxPaletteSize)
+		}
+		return ExtractPalette(target, n), nil
+	}
+	if colors, err := LoadPalette(value); !os.IsNotExist(err) {
+		return colors, err
+	}
+	var colors []Color
+	for _, x := range strings.Split(value, ",") {
+		c, err := parseHexColor(x)
+		if err != nil {
+			return nil, err
+		}
+		colors = append(colors, c)
+	}
+	return colors, nil
+}
+
+func LoadPalette(path string) ([]Color, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodePalette(data)
+}
+
+func decodePalette(data []byte) ([]Color, error) {
+	var colors []Color
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("ASEF")):
+		colors, err = decodeASE(data)
+	case bytes.HasPrefix(data, []byte("GIMP Palette")):
+		colors, err = decodeGPL(data)
+	default:
+		colors, err = decodeHexList(data)
+	}
+	if err == nil && len(colors) == 0 {
+		err = fmt.Errorf("palette contains no colors")
+	}
+	return colors, err
+}
+
+func parseHexColor(x string) (Color, error) {
+	x = strings.TrimSpace(x)
+	h := strings.TrimPrefix(x, "#")
+	if _, err := strconv.ParseUint(h, 16, 32); err != nil || (len(h) != 3 && len(h) != 6) {
+		return Color{}, fmt.Errorf("invalid palette color: %q", x)
+	}
+	return MakeHexColor(h), nil
+}
+
+func decodeHexList(data []byte) ([]Color, error) {
+	var colors []Color
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		c, err := parseHexColor(strings.Fields(line)[0])
+		if err != nil {
+			return nil, err
+		}
+		colors = append(colors, c)
+	}
+	return colors, scanner.Err()
+}
+
+func decodeGPL(data []byte) ([]Color, error) {
+	var colors []Color
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rgb [3]int
+		valid := true
+		for i := range rgb {
+			n, err := strconv.Atoi(fields[i])
+			if err != nil || n < 0 || n > 255 {
+				valid = false
+				break
+			}
+			rgb[i] = n
+		}
+		if valid {
+			colors = append(colors, Color{rgb[0], rgb[1], rgb[2], 255})
+		}
+	}
+	return colors, scanner.Err()
+}
+
+// decodeASE reads the color entries of an Adobe Swatch Exchange file.
+// RGB, CMYK and grayscale swatches are supported; LAB swatches are skipped.
+func decodeASE(data []byte) ([]Color, error) {
+	errInvalid := fmt.Errorf("invalid ase palette")
+	r := bytes.NewReader(data[4:])
+	var header struct {
+		Major, Minor uint16
+		Blocks       uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, errInvalid
+	}
+	var colors []Color
+	for i := 0; i < int(header.Blocks); i++ {
+		var kind uint16
+		var length uint32
+		if binary.Read(r, binary.BigEndian, &kind) != nil ||
+			binary.Read(r, binary.BigEndian, &length) != nil ||
+			int(length) > r.Len() {
+			return nil, errInvalid
+		}
+		block := make([]byte, length)
+		r.Read(block)
+		if kind != 0x0001 {
+			continue
+		}
+		br := bytes.NewReader(block)
+		var nameLength uint16
+		if binary.Read(br, binary.BigEndian, &nameLength) != nil {
+			return nil, errInvalid
+		}
+		name := make([]uint16, nameLength)
+		var model [4]byte
+		if binary.Read(br, binary.BigEndian, name) != nil ||
+			binary.Read(br, binary.BigEndian, &model) != nil {
+			return nil, errInvalid
+		}
+		var values [4]float32
+		var n int
+		switch string(model[:]) {
+		case "RGB ":
+			n = 3
+		case "CMYK":
+			n = 4
+		case "Gray":
+			n = 1
+		default:
+			vv("skipping %s swatch %q\n", model, string(utf16.Decode(name)))
+			continue
+		}
+		if binary.Read(br, binary.BigEndian, values[:n]) != nil {
+			return nil, errInvalid
+		}
+		var rf, gf, bf float64
+		switch n {
+		case 3:
+			rf, gf, bf = float64(values[0]), float64(values[1]), float64(values[2])
+		case 4:
+			k := 1 - float64(values[3])
+			rf = (1 - float64(values[0])) * k
+			gf = (1 - float64(values[1])) * k
+			bf = (1 - float64(values[2])) * k
+		case 1:
+			rf, gf, bf = float64(values[0]), float64(values[0]), float64(values[0])
+		}
+		colors = append(colors, Color{unitToByte(rf), unitToByte(gf), unitToByte(bf), 255})
+	}
+	return colors, nil
+}
+
+func unitToByte(x float64) int {
+	return clampInt(int(math.Floor(x*255+0.5)), 0, 255)
+}
+
+// ExtractPalette clusters the colors of an image with k-means and returns
+// the cluster centers ordered from most to least common.
+func ExtractPalette(im image.Image, k int) []Color {
+	const maxSamples = 16384
+	const iterations = 20
+	rgba := asRGBA(im)
+	n := len(rgba.Pix) / 4
+	step := maxInt(n/maxSamples, 1)
+	var samples [][3]float64
+	for i := 0; i < n; i += step {
+		p := rgba.Pix[i*4 : i*4+3]
+		samples = append(samples, [3]float64{float64(p[0]), float64(p[1]), float64(p[2])})
+	}
+	k = minInt(k, len(samples))
+	rnd := rand.New(rand.NewSource(1))
+
+	// k-means++ seeding
+	centers := [][3]float64{samples[rnd.Intn(len(samples))]}
+	distances := make([]float64, len(samples))
+	for len(centers) < k {
+		var total float64
+		for i, s := range samples {
+			distances[i] = math.Inf(1)
+			for _, c := range centers {
+				distances[i] = math.Min(distances[i], distance3(s, c))
+			}
+			total += distances[i]
+		}
+		if total == 0 {
+			break
+		}
+		x := rnd.Float64() * total
+		i := 0
+		for ; i < len(samples)-1 && x >= distances[i]; i++ {
+			x -= distances[i]
+		}
+		centers = append(centers, samples[i])
+	}
+
+	counts := make([]int, len(centers))
+	assignments := make([]int, len(samples))
+	for iteration := 0; iteration < iterations; iteration++ {
+		changed := false
+		for i, s := range samples {
+			best := 0
+			for j, c := range centers {
+				if distance3(s, c) < distance3(s, centers[best]) {
+					best = j
+				}
+			}
+			if iteration == 0 || assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		sums := make([][3]float64, len(centers))
+		for i := range counts {
+			counts[i] = 0
+		}
+		for i, s := range samples {
+			j := assignments[i]
+			counts[j]++
+			for c := 0; c < 3; c++ {
+				sums[j][c] += s[c]
+			}
+		}
+		for j := range centers {
+			if counts[j] > 0 {
+				for c := 0; c < 3; c++ {
+					centers[j][c] = sums[j][c] / float64(counts[j])
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	indexes := make([]int, len(centers))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return counts[indexes[i]] > counts[indexes[j]]
+	})
+	colors := make([]Color, len(centers))
+	for i, j := range indexes {
+		c := centers[j]
+		colors[i] = Color{int(c[0] + 0.5), int(c[1] + 0.5), int(c[2] + 0.5), 255}
+	}
+	return colors
+}
+
+func distance3(a, b [3]float64) float64 {
+	dr := a[0] - b[0]
+	dg := a[1] - b[1]
+	db := a[2] - b[2]
+	return dr*dr + dg*dg + db*db
+}
+
+func colorDistance(a, b Color) int {
+	dr := a.R - b.R
+	dg := a.G - b.G
+	db := a.B - b.B
+	return dr*dr + dg*dg + db*db
+}
+
+// paletteColor replaces an optimal color with the palette entry that gives
+// the lowest error. Only the entries nearest to the optimal color are drawn
+// into buffer and evaluated.
+func (cc *colorConfig) paletteColor(target, current, buffer *image.RGBA, lines []Scanline, c Color, score float64) Color {
+	candidates := make([]Color, len(cc.Palette))
+	copy(candidates, cc.Palette)
+	sort.Slice(candidates, func(i, j int) bool {
+		return colorDistance(candidates[i], c) < colorDistance(candidates[j], c)
+	})
+	if len(candidates) > paletteCandidates {
+		candidates = candidates[:paletteCandidates]
+	}
+	var bestColor Color
+	var bestEnergy float64
+	for i, p := range candidates {
+		p.A = c.A
+		copyLines(buffer, current, lines)
+		cc.drawLines(buffer, p, lines)
+		energy := cc.difference(target, current, buffer, score, lines)
+		if i == 0 || energy < bestEnergy {
+			bestEnergy = energy
+			bestColor = p
+		}
+	}
+	return bestColor
+}
@@ -0,0 +1,37 @@
+package primitive
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParsePalette(t *testing.T) {
+	target := uniformRGBA(image.Rect(0, 0, 8, 8), color.White)
+	tests := []struct {
+		value string
+		want  []Color
+	}{
+		{"000000", []Color{{0, 0, 0, 255}}},
+		{"112", []Color{{0x11, 0x11, 0x22, 255}}},
+		{"#102030,fff", []Color{{0x10, 0x20, 0x30, 255}, {255, 255, 255, 255}}},
+		{"1", []Color{{255, 255, 255, 255}}},
+		{"kmeans:100", []Color{{255, 255, 255, 255}}},
+	}
+	for _, test := range tests {
+		got, err := ParsePalette(test.value, target)
+		if err != nil {
+			t.Errorf("%q: %v", test.value, err)
+			continue
+		}
+		// a uniform target has a single distinct color to extract
+		if len(got) == 0 || got[0] != test.want[0] || (len(test.want) > 1 && len(got) != len(test.want)) {
+			t.Errorf("%q: got %v, want %v", test.value, got, test.want)
+		}
+	}
+	for _, value := range []string{"0", "1000", "kmeans:0", "kmeans:257", "kmeans:x", "red"} {
+		if _, err := ParsePalette(value, target); err == nil {
+			t.Errorf("%q: no error", value)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package primitive
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"fmt"
+	"strings"
+)
+
+// psPath writes paths with PDF or PostScript operators, which only differ
+// in their names.
+type psPath struct {
+	buf                      *bytes.Buffer
+	move, line, curve, close string
+}
+
+func newPDFPath(buf *bytes.Buffer) *psPath {
+	return &psPath{buf, "m", "l", "c", "h"}
+}
+
+func newPSPath(buf *bytes.Buffer) *psPath {
+	return &psPath{buf, "moveto", "lineto", "curveto", "closepath"}
+}
+
+func (p *psPath) MoveTo(x, y float64) {
+	fmt.Fprintf(p.buf, "%s %s\n", formatNumbers(2, x, y), p.move)
+}
+
+func (p *psPath) LineTo(x, y float64) {
+	fmt.Fprintf(p.buf, "%s %s\n", formatNumbers(2, x, y), p.line)
+}
+
+func (p *psPath) CubicTo(x1, y1, x2, y2, x3, y3 float64) {
+	fmt.Fprintf(p.buf, "%s %s\n", formatNumbers(2, x1, y1, x2, y2, x3, y3), p.curve)
+}
+
+func (p *psPath) ClosePath() {
+	fmt.Fprintf(p.buf, "%s\n", p.close)
+}
+
+func rgbNumbers(c Color) string {
+	return formatNumbers(3, float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+}
+
+// shadingDict describes the gradient background as an axial shading, which
+// PDF and PostScript level 3 share.
+func (model *Model) shadingDict() string {
+	g := model.Gradient.Map(model.outputColor)
+	h := float64(model.Sh)
+	s := model.Scale
+	return fmt.Sprintf("<< /ShadingType 2 /ColorSpace /DeviceRGB /Coords [%s] /Extend [true true] "+
+		"/Function << /FunctionType 2 /Domain [0 1] /C0 [%s] /C1 [%s] /N 1 >> >>",
+		formatNumbers(2, g.X1*s, h-g.Y1*s, g.X2*s, h-g.Y2*s),
+		rgbNumbers(g.Color1), rgbNumbers(g.Color2))
+}
+
+// pdfBlendModes maps blend modes to PDF blend mode names. PDF has no
+// additive blend mode, so add is drawn as normal.
+var pdfBlendModes = map[BlendMode]string{
+	BlendNormal:     "Normal",
+	BlendMultiply:   "Multiply",
+	BlendScreen:     "Screen",
+	BlendAdd:        "Normal",
+	BlendDifference: "Difference",
+}
+
+// PDF returns a single page PDF document of the output size, in points,
+// with the shapes as vector paths.
+func (model *Model) PDF() []byte {
+	var content bytes.Buffer
+	var states []string
+	stateIndex := map[int]int{}
+	current := -1
+	setAlpha := func(a int) {
+		i, ok := stateIndex[a]
+		if !ok {
+			i = len(states)
+			stateIndex[a] = i
+			states = append(states, fmt.Sprintf("<< /Type /ExtGState /ca %s /CA %s /BM /%s >>",
+				formatNumber(float64(a)/255, 3), formatNumber(float64(a)/255, 3), pdfBlendModes[model.Blend]))
+		}
+		if i != current {
+			fmt.Fprintf(&content, "/G%d gs\n", i)
+			current = i
+		}
+	}
+
+	bg := model.outputColor(model.Background)
+	if model.BaseImage != nil {
+		fmt.Fprintf(&content, "q %d 0 0 %d 0 0 cm /Im0 Do Q\n", model.Sw, model.Sh)
+	} else if model.Gradient != nil {
+		content.WriteString("/Sh0 sh\n")
+	} else if bg.A > 0 {
+		if bg.A < 255 {
+			fmt.Fprintf(&content, "/GB gs\n")
+		}
+		fmt.Fprintf(&content, "%s rg\n0 0 %d %d re f\n", rgbNumbers(bg), model.Sw, model.Sh)
+	}
+	path := model.outputPath(newPDFPath(&content), true)
+	for i, shape := range model.Shapes {
+		c := model.outputColor(model.Colors[i])
+		setAlpha(c.A)
+		if w, ok := strokeWidth(shape); ok {
+			fmt.Fprintf(&content, "%s RG %s w\n", rgbNumbers(c), formatNumber(w*model.Scale, 2))
+			shape.Path(path)
+			content.WriteString("S\n")
+		} else {
+			fmt.Fprintf(&content, "%s rg\n", rgbNumbers(c))
+			shape.Path(path)
+			content.WriteString("f\n")
+		}
+	}
+
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write(content.Bytes())
+	zw.Close()
+
+	// objects 1-4 are fixed, graphics states and the shading follow
+	var objects []string
+	var resources []string
+	var gs []string
+	for i, s := range states {
+		gs = append(gs, fmt.Sprintf("/G%d %d 0 R", i, 5+i))
+		objects = append(objects, s)
+	}
+	if model.BaseImage == nil && model.Gradient == nil && bg.A > 0 && bg.A < 255 {
+		gs = append(gs, fmt.Sprintf("/GB %d 0 R", 5+len(objects)))
+		objects = append(objects, fmt.Sprintf("<< /Type /ExtGState /ca %s >>", formatNumber(float64(bg.A)/255, 3)))
+	}
+	if len(gs) > 0 {
+		resources = append(resources, "/ExtGState << "+strings.Join(gs, " ")+" >>")
+	}
+	if model.BaseImage != nil {
+		data := model.baseRGB()
+		resources = append(resources, fmt.Sprintf("/XObject << /Im0 %d 0 R >>", 5+len(objects)))
+		objects = append(objects, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d "+
+			"/ColorSpace /DeviceRGB /BitsPerComponent 8 /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+			model.Sw, model.Sh, len(data), data))
+	} else if model.Gradient != nil {
+		resources = append(resources, fmt.Sprintf("/Shading << /Sh0 %d 0 R >>", 5+len(objects)))
+		objects = append(objects, model.shadingDict())
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents 4 0 R /Resources << %s >> >>",
+			model.Sw, model.Sh, strings.Join(resources, " ")),
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.Bytes()),
+	}, objects...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, o := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// EPS returns an Encapsulated PostScript document. PostScript has no
+// transparency or blend modes, so shapes are drawn opaque; use PDF output
+// for translucent shapes.
+func (model *Model) EPS() string {
+	var buf bytes.Buffer
+	buf.WriteString("%!PS-Adobe-3.0 EPSF-3.0\n")
+	fmt.Fprintf(&buf, "%%%%BoundingBox: 0 0 %d %d\n", model.Sw, model.Sh)
+	buf.WriteString("%%LanguageLevel: 3\n%%EndComments\n")
+	bg := model.outputColor(model.Background)
+	if model.BaseImage != nil {
+		// the image data follows the image operator, ascii85 encoded
+		fmt.Fprintf(&buf, "gsave\n%d %d scale\n/DeviceRGB setcolorspace\n", model.Sw, model.Sh)
+		fmt.Fprintf(&buf, "<< /ImageType 1 /Width %d /Height %d /BitsPerComponent 8 /Decode [0 1 0 1 0 1] "+
+			"/ImageMatrix [%d 0 0 -%d 0 %d] /DataSource currentfile /ASCII85Decode filter /FlateDecode filter >> image\n",
+			model.Sw, model.Sh, model.Sw, model.Sh, model.Sh)
+		data := model.baseRGB()
+		encoded := make([]byte, ascii85.MaxEncodedLen(len(data)))
+		encoded = encoded[:ascii85.Encode(encoded, data)]
+		for len(encoded) > 0 {
+			n := minInt(len(encoded), 76)
+			buf.Write(encoded[:n])
+			buf.WriteString("\n")
+			encoded = encoded[n:]
+		}
+		buf.WriteString("~>\ngrestore\n")
+	} else if model.Gradient != nil {
+		fmt.Fprintf(&buf, "%s shfill\n", model.shadingDict())
+	} else if bg.A > 0 {
+		fmt.Fprintf(&buf, "%s setrgbcolor\n0 0 %d %d rectfill\n", rgbNumbers(bg), model.Sw, model.Sh)
+	}
+	path := model.outputPath(newPSPath(&buf), true)
+	for i, shape := range model.Shapes {
+		c := model.outputColor(model.Colors[i])
+		fmt.Fprintf(&buf, "%s setrgbcolor\nnewpath\n", rgbNumbers(c))
+		shape.Path(path)
+		if w, ok := strokeWidth(shape); ok {
+			fmt.Fprintf(&buf, "%s setlinewidth stroke\n", formatNumber(w*model.Scale, 2))
+		} else {
+			buf.WriteString("fill\n")
+		}
+	}
+	buf.WriteString("showpage\n%%EOF\n")
+	return buf.String()
+}
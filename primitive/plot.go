@@ -0,0 +1,130 @@
+package primitive
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PlotOptions controls plotter output. Plotters draw lines with a pen, so
+// filled shapes are drawn as their outline plus parallel hatching, denser
+// for darker and more opaque shapes. Later shapes don't cover earlier ones
+// and the background, or base image, is left to the paper.
+type PlotOptions struct {
+	Size    float64 // millimeters of the longer side of the drawing
+	Spacing float64 // hatch spacing in output pixels for the darkest shapes
+}
+
+// plotLines returns the polylines to draw, in millimeters with y up.
+func (model *Model) plotLines(opt PlotOptions) [][]float64 {
+	mm := opt.Size / float64(maxInt(model.Sw, model.Sh))
+	var result [][]float64
+	for i, shape := range model.Shapes {
+		lines := &polylines{}
+		shape.Path(model.outputPath(lines, true))
+		if _, ok := strokeWidth(shape); !ok {
+			c := model.outputColor(model.Colors[i])
+			darkness := (1 - float64(luminance(c.R, c.G, c.B))/255) * float64(c.A) / 255
+			if darkness < 0.05 {
+				continue
+			}
+			// alternate hatching directions so overlapping shapes cross hatch
+			angle := float64(i%4) * 45
+			hatching := hatch(lines.lines, opt.Spacing/darkness, angle)
+			for j, line := range lines.lines {
+				if lines.closed[j] {
+					lines.lines[j] = append(line, line[0], line[1])
+				}
+			}
+			lines.lines = append(lines.lines, hatching...)
+		}
+		for _, line := range lines.lines {
+			for j := range line {
+				line[j] *= mm
+			}
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// hatch fills closed polygons with parallel lines at the given spacing and
+// angle in degrees, using the even-odd rule.
+func hatch(polygons [][]float64, spacing, angle float64) [][]float64 {
+	a := radians(angle)
+	c, s := math.Cos(a), math.Sin(a)
+	// rotate so the hatch lines are horizontal
+	var edges [][4]float64
+	y0, y1 := math.Inf(1), math.Inf(-1)
+	for _, p := range polygons {
+		n := len(p) / 2
+		for i := 0; i < n; i++ {
+			j := (i + 1) % n
+			ax, ay := p[2*i]*c+p[2*i+1]*s, -p[2*i]*s+p[2*i+1]*c
+			bx, by := p[2*j]*c+p[2*j+1]*s, -p[2*j]*s+p[2*j+1]*c
+			edges = append(edges, [4]float64{ax, ay, bx, by})
+			y0 = math.Min(y0, ay)
+			y1 = math.Max(y1, ay)
+		}
+	}
+	var result [][]float64
+	row := 0
+	for y := y0 + spacing/2; y < y1; y += spacing {
+		var xs []float64
+		for _, e := range edges {
+			if (e[1] <= y) != (e[3] <= y) {
+				xs = append(xs, e[0]+(y-e[1])*(e[2]-e[0])/(e[3]-e[1]))
+			}
+		}
+		sort.Float64s(xs)
+		if row%2 == 1 {
+			// go back and forth to keep pen travel short
+			for i, j := 0, len(xs)-1; i < j; i, j = i+1, j-1 {
+				xs[i], xs[j] = xs[j], xs[i]
+			}
+		}
+		for i := 0; i+1 < len(xs); i += 2 {
+			result = append(result, []float64{
+				xs[i]*c - y*s, xs[i]*s + y*c,
+				xs[i+1]*c - y*s, xs[i+1]*s + y*c,
+			})
+		}
+		row++
+	}
+	return result
+}
+
+// HPGL returns plotter instructions in HP-GL, in plotter units of 0.025mm.
+func (model *Model) HPGL(opt PlotOptions) string {
+	var buf bytes.Buffer
+	buf.WriteString("IN;SP1;\n")
+	for _, line := range model.plotLines(opt) {
+		fmt.Fprintf(&buf, "PU%d,%d;PD", int(math.Round(line[0]*40)), int(math.Round(line[1]*40)))
+		for i := 2; i+1 < len(line); i += 2 {
+			if i > 2 {
+				buf.WriteString(",")
+			}
+			fmt.Fprintf(&buf, "%d,%d", int(math.Round(line[i]*40)), int(math.Round(line[i+1]*40)))
+		}
+		buf.WriteString(";\n")
+	}
+	buf.WriteString("PU;SP0;\n")
+	return buf.String()
+}
+
+// GCode returns plotter instructions in G-code, in millimeters, raising
+// and lowering the pen on the Z axis.
+func (model *Model) GCode(opt PlotOptions) string {
+	var buf bytes.Buffer
+	buf.WriteString("G21\nG90\nG0 Z5\n")
+	for _, line := range model.plotLines(opt) {
+		fmt.Fprintf(&buf, "G0 X%s Y%s\nG1 Z0 F1000\n", formatNumber(line[0], 2), formatNumber(line[1], 2))
+		for i := 2; i+1 < len(line); i += 2 {
+			fmt.Fprintf(&buf, "G1 X%s Y%s F3000\n", formatNumber(line[i], 2), formatNumber(line[i+1], 2))
+		}
+		buf.WriteString("G0 Z5\n")
+	}
+	buf.WriteString("G0 X0 Y0\n")
+	return buf.String()
+}
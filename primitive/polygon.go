@@ -9,7 +9,7 @@ import (
 )
 
 type Polygon struct {
-	Worker *Worker
+	Worker *Worker `json:"-"`
 	Order  int
 	Convex bool
 	X, Y   []float64
@@ -51,6 +51,22 @@ func (p *Polygon) SVG(attrs string) string {
 	return ret + strings.Join(points, ",") + "\" />"
 }
 
+func (p *Polygon) svgPath(precision int) string {
+	points := make([]float64, 0, 2*p.Order)
+	for i := 0; i < p.Order; i++ {
+		points = append(points, p.X[i], p.Y[i])
+	}
+	return polygonPath(precision, points...)
+}
+
+func (p *Polygon) Path(w PathWriter) {
+	points := make([]float64, 0, 2*p.Order)
+	for i := 0; i < p.Order; i++ {
+		points = append(points, p.X[i], p.Y[i])
+	}
+	writePolygon(w, points...)
+}
+
 func (p *Polygon) Copy() Shape {
 	a := *p
 	a.X = make([]float64, p.Order)
@@ -60,6 +76,15 @@ func (p *Polygon) Copy() Shape {
 	return &a
 }
 
+func (p *Polygon) Scaled(worker *Worker, scale float64) Shape {
+	a := &Polygon{worker, p.Order, p.Convex, make([]float64, p.Order), make([]float64, p.Order)}
+	for i := 0; i < p.Order; i++ {
+		a.X[i] = p.X[i] * scale
+		a.Y[i] = p.Y[i] * scale
+	}
+	return a
+}
+
 func (p *Polygon) Mutate() {
 	const m = 16
 	w := p.Worker.W
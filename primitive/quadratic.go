@@ -9,7 +9,7 @@ import (
 )
 
 type Quadratic struct {
-	Worker *Worker
+	Worker *Worker `json:"-"`
 	X1, Y1 float64
 	X2, Y2 float64
 	X3, Y3 float64
@@ -45,11 +45,33 @@ func (q *Quadratic) SVG(attrs string) string {
 		attrs, q.X1, q.Y1, q.X2, q.Y2, q.X3, q.Y3, q.Width)
 }
 
+func (q *Quadratic) svgPath(precision int) string {
+	return fmt.Sprintf("M%sQ%s", formatNumbers(precision, q.X1, q.Y1),
+		formatNumbers(precision, q.X2, q.Y2, q.X3, q.Y3))
+}
+
+// Path writes the curve as an open cubic bezier, to be stroked.
+func (q *Quadratic) Path(p PathWriter) {
+	p.MoveTo(q.X1, q.Y1)
+	p.CubicTo(
+		q.X1+(q.X2-q.X1)*2/3, q.Y1+(q.Y2-q.Y1)*2/3,
+		q.X3+(q.X2-q.X3)*2/3, q.Y3+(q.Y2-q.Y3)*2/3,
+		q.X3, q.Y3)
+}
+
 func (q *Quadratic) Copy() Shape {
 	a := *q
 	return &a
 }
 
+func (q *Quadratic) Scaled(worker *Worker, scale float64) Shape {
+	return &Quadratic{worker,
+		q.X1 * scale, q.Y1 * scale,
+		q.X2 * scale, q.Y2 * scale,
+		q.X3 * scale, q.Y3 * scale,
+		q.Width * scale}
+}
+
 func (q *Quadratic) Mutate() {
 	const m = 16
 	w := q.Worker.W
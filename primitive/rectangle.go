@@ -8,7 +8,7 @@ import (
 )
 
 type Rectangle struct {
-	Worker *Worker
+	Worker *Worker `json:"-"`
 	X1, Y1 int
 	X2, Y2 int
 }
@@ -49,11 +49,32 @@ func (r *Rectangle) SVG(attrs string) string {
 		attrs, x1, y1, w, h)
 }
 
+func (r *Rectangle) svgPath(precision int) string {
+	x1, y1, x2, y2 := r.bounds()
+	return fmt.Sprintf("M%d %dh%dv%dh%dZ", x1, y1, x2-x1+1, y2-y1+1, x1-x2-1)
+}
+
+func (r *Rectangle) Path(p PathWriter) {
+	x1, y1, x2, y2 := r.bounds()
+	writePolygon(p,
+		float64(x1), float64(y1), float64(x2+1), float64(y1),
+		float64(x2+1), float64(y2+1), float64(x1), float64(y2+1))
+}
+
 func (r *Rectangle) Copy() Shape {
 	a := *r
 	return &a
 }
 
+func (r *Rectangle) Scaled(worker *Worker, scale float64) Shape {
+	x1, y1, x2, y2 := r.bounds()
+	x1 = clampInt(scaleStart(x1, scale), 0, worker.W-1)
+	y1 = clampInt(scaleStart(y1, scale), 0, worker.H-1)
+	x2 = clampInt(scaleEnd(x2, scale), x1, worker.W-1)
+	y2 = clampInt(scaleEnd(y2, scale), y1, worker.H-1)
+	return &Rectangle{worker, x1, y1, x2, y2}
+}
+
 func (r *Rectangle) Mutate() {
 	w := r.Worker.W
 	h := r.Worker.H
@@ -78,7 +99,7 @@ func (r *Rectangle) Rasterize() []Scanline {
 }
 
 type RotatedRectangle struct {
-	Worker *Worker
+	Worker *Worker `json:"-"`
 	X, Y   int
 	Sx, Sy int
 	Angle  int
@@ -112,11 +133,39 @@ func (r *RotatedRectangle) SVG(attrs string) string {
 		r.X, r.Y, r.Angle, r.Sx, r.Sy, attrs)
 }
 
+// corners returns the x, y coordinates of the four corners.
+func (r *RotatedRectangle) corners() []float64 {
+	a := radians(float64(r.Angle))
+	c, s := math.Cos(a), math.Sin(a)
+	hx, hy := float64(r.Sx)/2, float64(r.Sy)/2
+	var points []float64
+	for _, p := range [][2]float64{{-hx, -hy}, {hx, -hy}, {hx, hy}, {-hx, hy}} {
+		points = append(points,
+			float64(r.X)+p[0]*c-p[1]*s,
+			float64(r.Y)+p[0]*s+p[1]*c)
+	}
+	return points
+}
+
+func (r *RotatedRectangle) svgPath(precision int) string {
+	return polygonPath(precision, r.corners()...)
+}
+
+func (r *RotatedRectangle) Path(p PathWriter) {
+	writePolygon(p, r.corners()...)
+}
+
 func (r *RotatedRectangle) Copy() Shape {
 	a := *r
 	return &a
 }
 
+func (r *RotatedRectangle) Scaled(worker *Worker, scale float64) Shape {
+	return &RotatedRectangle{worker,
+		scaleInt(r.X, scale), scaleInt(r.Y, scale),
+		scaleSize(r.Sx, scale), scaleSize(r.Sy, scale), r.Angle}
+}
+
 func (r *RotatedRectangle) Mutate() {
 	w := r.Worker.W
 	h := r.Worker.H
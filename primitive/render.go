@@ -0,0 +1,65 @@
+package primitive
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/golang/freetype/raster"
+)
+
+// Renderer selects how output images are drawn.
+type Renderer int
+
+const (
+	// RendererNative scales each shape to the output size and draws it with
+	// the same scanline rasterization that was used to score it, so output
+	// images match what was optimized.
+	RendererNative Renderer = iota
+	// RendererGG draws shapes as antialiased paths with gg, which matches
+	// the SVG output more closely than the scored image.
+	RendererGG
+)
+
+func ParseRenderer(name string) (Renderer, bool) {
+	switch name {
+	case "native":
+		return RendererNative, true
+	case "gg":
+		return RendererGG, true
+	}
+	return 0, false
+}
+
+// SetRenderer changes how output images are drawn and redraws the shapes
+// added so far.
+func (model *Model) SetRenderer(renderer Renderer) {
+	model.Renderer = renderer
+	model.Context = model.newContext()
+	for i, shape := range model.Shapes {
+		model.drawShape(model.Context, shape, model.Colors[i])
+	}
+}
+
+// outputWorker returns a worker sized to the output image. It is only used
+// to rasterize scaled shapes, so it has no target or buffers.
+func (model *Model) outputWorker() *Worker {
+	if model.output == nil {
+		w, h := model.Sw, model.Sh
+		model.output = &Worker{W: w, H: h, Rasterizer: raster.NewRasterizer(w, h)}
+	}
+	return model.output
+}
+
+func (model *Model) drawShapeNative(im *image.RGBA, shape Shape, c Color) {
+	lines := shape.Scaled(model.outputWorker(), model.Scale).Rasterize()
+	drawLinesBlend(im, c, lines, model.Blend)
+}
+
+func (model *Model) drawGradientNative(im *image.RGBA) {
+	g := *model.Gradient.Map(model.outputColor)
+	g.X1 *= model.Scale
+	g.Y1 *= model.Scale
+	g.X2 *= model.Scale
+	g.Y2 *= model.Scale
+	draw.Draw(im, im.Bounds(), g.Image(model.Sw, model.Sh), image.ZP, draw.Src)
+}
@@ -0,0 +1,54 @@
+package primitive
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+// TestNativeRenderMatchesCurrent checks that at scale 1 the native output
+// image is the image that was scored.
+func TestNativeRenderMatchesCurrent(t *testing.T) {
+	im, err := LoadImage("../examples/lenna.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im = resize.Thumbnail(48, 48, im, resize.Bilinear)
+	for _, st := range kernelShapeTypes {
+		model := NewModel(im, MakeColor(AverageImageColor(im)), 48, 1)
+		model.Seed(1)
+		for i := 0; i < 5; i++ {
+			model.Step(st, 128, 0)
+		}
+		out := model.Context.Image().(*image.RGBA)
+		if !bytes.Equal(out.Pix, model.Current.Pix) {
+			t.Errorf("shape type %d: output image differs from the scored image", st)
+		}
+	}
+}
+
+func TestTiledNativeRenderMatchesCurrent(t *testing.T) {
+	im, err := LoadImage("../examples/lenna.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im = resize.Thumbnail(48, 48, im, resize.Bilinear)
+	tm := NewTiledModel(im, MakeColor(AverageImageColor(im)), 48, 2, 2, 4, 1)
+	tm.Seed(1)
+	for i := 0; i < 5; i++ {
+		tm.Step(ShapeTypeTriangle, 128, 0)
+	}
+	out := tm.Image().(*image.RGBA)
+	for _, tile := range tm.Tiles {
+		current := tile.Model.Current
+		for y := tile.Region.Min.Y; y < tile.Region.Max.Y; y++ {
+			for x := tile.Region.Min.X; x < tile.Region.Max.X; x++ {
+				if got, want := out.RGBAAt(x, y), current.RGBAAt(x-tile.X, y-tile.Y); got != want {
+					t.Fatalf("pixel %d,%d is %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	}
+}
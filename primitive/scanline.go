@@ -1,5 +1,7 @@
 package primitive
 
+import "image"
+
 type Scanline struct {
 	Y, X1, X2 int
 	Alpha     uint32
@@ -27,3 +29,13 @@ func cropScanlines(lines []Scanline, w, h int) []Scanline {
 	}
 	return lines[:i]
 }
+
+// scanlineBounds returns the smallest rectangle containing every pixel
+// covered by lines.
+func scanlineBounds(lines []Scanline) image.Rectangle {
+	var r image.Rectangle
+	for _, line := range lines {
+		r = r.Union(image.Rect(line.X1, line.Y, line.X2+1, line.Y+1))
+	}
+	return r
+}
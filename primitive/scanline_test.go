@@ -0,0 +1,40 @@
+package primitive
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestCropScanlines(t *testing.T) {
+	lines := []Scanline{
+		{-1, 0, 5, 0xffff},
+		{0, -5, 5, 0xffff},
+		{1, 3, 4, 0x8000},
+		{2, 8, 20, 0xffff},
+		{3, 10, 12, 0xffff},
+		{4, -3, -1, 0xffff},
+		{10, 0, 5, 0xffff},
+	}
+	got := cropScanlines(lines, 10, 10)
+	want := []Scanline{
+		{0, 0, 5, 0xffff},
+		{1, 3, 4, 0x8000},
+		{2, 8, 9, 0xffff},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestScanlineBounds(t *testing.T) {
+	lines := []Scanline{{2, 3, 4, 0xffff}, {5, 1, 1, 0xffff}}
+	got := scanlineBounds(lines)
+	want := image.Rect(1, 2, 5, 6)
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !scanlineBounds(nil).Empty() {
+		t.Errorf("bounds of no lines should be empty")
+	}
+}
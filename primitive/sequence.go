@@ -0,0 +1,91 @@
+package primitive
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Refit adds the shapes of the model for the previous frame of an image
+// sequence, hill climbing each one from where it was against this model's
+// target instead of searching from scratch. Shape i stays shape i and only
+// moves when that improves the match, so the frames don't flicker. Shapes
+// keep their alpha and get new colors. It returns the number of states
+// evaluated.
+func (model *Model) Refit(prev *Model, age int) int {
+	counter := 0
+	ch := make(chan *State, len(model.Workers))
+	for i, shape := range prev.Shapes {
+		alpha := prev.Colors[i].A
+		for _, worker := range model.Workers {
+			worker.Init(model.Current, model.Score)
+			go func(state *State) {
+				ch <- HillClimb(state, age).(*State)
+			}(NewState(worker, shape.Scaled(worker, 1), alpha))
+		}
+		var best *State
+		for range model.Workers {
+			state := <-ch
+			if best == nil || state.Energy() < best.Energy() {
+				best = state
+			}
+		}
+		for _, worker := range model.Workers {
+			counter += worker.Counter
+		}
+		model.Add(best.Shape, best.Alpha)
+	}
+	return counter
+}
+
+// Track records the shapes of each frame of a sequence, grouped by shape
+// so each shape's parameters can be followed over time.
+type Track struct {
+	Width      int          `json:"width"`
+	Height     int          `json:"height"`
+	Scale      float64      `json:"scale"`
+	Background Color        `json:"background"`
+	Blend      string       `json:"blend"`
+	Frames     int          `json:"frames"`
+	Shapes     []trackShape `json:"shapes"`
+}
+
+type trackShape struct {
+	Type   string       `json:"type"`
+	Frames []trackFrame `json:"frames"`
+}
+
+type trackFrame struct {
+	Color Color           `json:"color"`
+	Shape json.RawMessage `json:"shape"`
+}
+
+// Add appends a frame. Every frame must have the same shapes, as models
+// made with Refit do.
+func (t *Track) Add(model *Model) error {
+	if t.Frames == 0 {
+		t.Width, t.Height, t.Scale = model.Sw, model.Sh, model.Scale
+		t.Background = model.outputColor(model.Background)
+		t.Blend = model.Blend.String()
+		for _, shape := range model.Shapes {
+			t.Shapes = append(t.Shapes, trackShape{Type: shapeName(shape)})
+		}
+	}
+	if len(model.Shapes) != len(t.Shapes) {
+		return fmt.Errorf("frame %d has %d shapes, want %d", t.Frames+1, len(model.Shapes), len(t.Shapes))
+	}
+	for i, shape := range model.Shapes {
+		data, err := json.Marshal(shape)
+		if err != nil {
+			return err
+		}
+		s := &t.Shapes[i]
+		s.Frames = append(s.Frames, trackFrame{model.outputColor(model.Colors[i]), data})
+	}
+	t.Frames++
+	return nil
+}
+
+func (t *Track) JSON() (string, error) {
+	data, err := json.MarshalIndent(t, "", "  ")
+	return string(data), err
+}
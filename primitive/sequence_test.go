@@ -0,0 +1,76 @@
+package primitive
+
+import (
+	"encoding/json"
+	"image"
+	"image/draw"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+func TestRefit(t *testing.T) {
+	im, err := LoadImage("../examples/lenna.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im = resize.Thumbnail(48, 48, im, resize.Bilinear)
+	// the next frame pans the image by a few pixels
+	next := image.NewRGBA(im.Bounds())
+	draw.Draw(next, next.Rect, im, image.Pt(3, 2), draw.Src)
+
+	bg := MakeColor(AverageImageColor(im))
+	first := NewModel(im, bg, 96, 2)
+	first.Seed(1)
+	for i := 0; i < 10; i++ {
+		first.Step(ShapeTypeAny, 128, 0)
+	}
+	second := NewModel(next, bg, 96, 2)
+	second.Seed(2)
+	start := second.Score
+	if n := second.Refit(first, 20); n == 0 {
+		t.Error("refit evaluated no states")
+	}
+	if len(second.Shapes) != len(first.Shapes) {
+		t.Fatalf("got %d shapes, want %d", len(second.Shapes), len(first.Shapes))
+	}
+	for i, shape := range second.Shapes {
+		if got, want := shapeName(shape), shapeName(first.Shapes[i]); got != want {
+			t.Errorf("shape %d: got %s, want %s", i, got, want)
+		}
+		if second.Colors[i].A != 128 {
+			t.Errorf("shape %d: got alpha %d, want 128", i, second.Colors[i].A)
+		}
+	}
+	if second.Score >= start {
+		t.Errorf("refit score %f is not below the starting score %f", second.Score, start)
+	}
+
+	var track Track
+	if err := track.Add(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := track.Add(second); err != nil {
+		t.Fatal(err)
+	}
+	data, err := track.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result Track
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Frames != 2 || len(result.Shapes) != 10 || len(result.Shapes[0].Frames) != 2 {
+		t.Errorf("got %d frames and %d shapes with %d frames, want 2, 10 and 2",
+			result.Frames, len(result.Shapes), len(result.Shapes[0].Frames))
+	}
+	if result.Shapes[3].Type != shapeName(first.Shapes[3]) {
+		t.Errorf("got type %s, want %s", result.Shapes[3].Type, shapeName(first.Shapes[3]))
+	}
+
+	first.Step(ShapeTypeTriangle, 128, 0)
+	if err := track.Add(first); err == nil {
+		t.Error("added a frame with a different number of shapes")
+	}
+}
@@ -1,13 +1,19 @@
 package primitive
 
-import "github.com/fogleman/gg"
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
 
 type Shape interface {
 	Rasterize() []Scanline
 	Copy() Shape
+	Scaled(worker *Worker, scale float64) Shape
 	Mutate()
 	Draw(dc *gg.Context, scale float64)
 	SVG(attrs string) string
+	Path(p PathWriter)
 }
 
 type ShapeType int
@@ -23,3 +29,49 @@ const (
 	ShapeTypeRotatedEllipse
 	ShapeTypePolygon
 )
+
+// shapeName returns the name of a shape's type, as used in SVG metadata.
+func shapeName(shape Shape) string {
+	switch s := shape.(type) {
+	case *Triangle:
+		return "triangle"
+	case *Rectangle:
+		return "rectangle"
+	case *Ellipse:
+		if s.Circle {
+			return "circle"
+		}
+		return "ellipse"
+	case *RotatedRectangle:
+		return "rotatedrectangle"
+	case *Quadratic:
+		return "quadratic"
+	case *RotatedEllipse:
+		return "rotatedellipse"
+	case *Polygon:
+		return "polygon"
+	}
+	return "shape"
+}
+
+// Shapes rasterized as paths use continuous coordinates and scale directly.
+// Shapes rasterized by scanline fills treat integer coordinates as pixel
+// indexes, so they are scaled about pixel centers with these helpers.
+
+func scaleInt(x int, scale float64) int {
+	return int(math.Floor((float64(x) + 0.5) * scale))
+}
+
+// scaleStart and scaleEnd scale an inclusive pixel range, keeping the
+// output pixels whose centers fall inside the scaled range.
+func scaleStart(x int, scale float64) int {
+	return int(math.Ceil(float64(x)*scale - 0.5))
+}
+
+func scaleEnd(x int, scale float64) int {
+	return int(math.Ceil(float64(x+1)*scale-0.5)) - 1
+}
+
+func scaleSize(x int, scale float64) int {
+	return maxInt(int(math.Floor(float64(x)*scale+0.5)), 1)
+}
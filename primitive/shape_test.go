@@ -0,0 +1,71 @@
+package primitive
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func coverage(lines []Scanline, w, h int) []float64 {
+	c := make([]float64, w*h)
+	for _, line := range lines {
+		for x := line.X1; x <= line.X2; x++ {
+			c[line.Y*w+x] = float64(line.Alpha) / 0xffff
+		}
+	}
+	return c
+}
+
+// TestRasterizeMatchesDraw checks that the scanlines used for scoring
+// cover the same pixels that the output drawing does, with the model's
+// half pixel offset. Antialiased pixels within one pixel of an edge are
+// allowed to differ.
+func TestRasterizeMatchesDraw(t *testing.T) {
+	const w, h = 100, 80
+	const tolerance = 0.01
+	worker := NewWorker(image.NewRGBA(image.Rect(0, 0, w, h)))
+	worker.Rnd = rand.New(rand.NewSource(1))
+	for _, st := range kernelShapeTypes {
+		var area, diff float64
+		for i := 0; i < 50; i++ {
+			shape := worker.RandomState(st, 255).Shape
+			c := coverage(shape.Rasterize(), w, h)
+			dc := gg.NewContext(w, h)
+			dc.SetRGB(0, 0, 0)
+			dc.Translate(0.5, 0.5)
+			shape.Draw(dc, 1)
+			im := dc.Image().(*image.RGBA)
+			alpha := func(x, y int) uint8 {
+				x = clampInt(x, 0, w-1)
+				y = clampInt(y, 0, h-1)
+				return im.Pix[(y*w+x)*4+3]
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					a := alpha(x, y)
+					area += float64(a) / 255
+					edge := false
+					for dy := -1; dy <= 1; dy++ {
+						for dx := -1; dx <= 1; dx++ {
+							if b := alpha(x+dx, y+dy); b != a || (b != 0 && b != 255) {
+								edge = true
+							}
+						}
+					}
+					if !edge && math.Abs(float64(a)/255-c[y*w+x]) > 0.5 {
+						diff++
+					}
+				}
+			}
+		}
+		if area == 0 {
+			t.Fatalf("shape type %d: nothing drawn", st)
+		}
+		if diff/area > tolerance {
+			t.Errorf("shape type %d: rasterized coverage differs from drawn coverage in %.1f%% of interior pixels", st, diff/area*100)
+		}
+	}
+}
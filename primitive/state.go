@@ -1,20 +1,16 @@
 package primitive
 
+// State is a candidate shape being optimized. An Alpha of 0 means that the
+// alpha is solved for along with the color whenever the shape is evaluated.
 type State struct {
-	Worker      *Worker
-	Shape       Shape
-	Alpha       int
-	MutateAlpha bool
-	Score       float64
+	Worker *Worker
+	Shape  Shape
+	Alpha  int
+	Score  float64
 }
 
 func NewState(worker *Worker, shape Shape, alpha int) *State {
-	var mutateAlpha bool
-	if alpha == 0 {
-		alpha = 128
-		mutateAlpha = true
-	}
-	return &State{worker, shape, alpha, mutateAlpha, -1}
+	return &State{worker, shape, alpha, -1}
 }
 
 func (state *State) Energy() float64 {
@@ -25,12 +21,8 @@ func (state *State) Energy() float64 {
 }
 
 func (state *State) DoMove() interface{} {
-	rnd := state.Worker.Rnd
 	oldState := state.Copy()
 	state.Shape.Mutate()
-	if state.MutateAlpha {
-		state.Alpha = clampInt(state.Alpha+rnd.Intn(21)-10, 1, 255)
-	}
 	state.Score = -1
 	return oldState
 }
@@ -38,11 +30,9 @@ func (state *State) DoMove() interface{} {
 func (state *State) UndoMove(undo interface{}) {
 	oldState := undo.(*State)
 	state.Shape = oldState.Shape
-	state.Alpha = oldState.Alpha
 	state.Score = oldState.Score
 }
 
 func (state *State) Copy() Annealable {
-	return &State{
-		state.Worker, state.Shape.Copy(), state.Alpha, state.MutateAlpha, state.Score}
+	return &State{state.Worker, state.Shape.Copy(), state.Alpha, state.Score}
 }
@@ -0,0 +1,88 @@
+package primitive
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SVGAnimation selects how AnimatedSVG reveals the shapes.
+type SVGAnimation struct {
+	CSS        bool    // use a CSS animation instead of SMIL
+	Pop        bool    // show each batch at once instead of fading it in
+	Duration   float64 // seconds over which the batches appear
+	Fade       float64 // seconds each batch takes to fade in
+	Easing     string  // fade easing, as accepted by ParseEasing
+	ScoreDelta float64 // minimum score improvement per batch, as in Frames
+}
+
+// easingCurves approximates the ParseEasing curves as cubic beziers for
+// SMIL keySplines and CSS timing functions.
+var easingCurves = map[string][4]float64{
+	"linear": {0, 0, 1, 1},
+	"in":     {0.32, 0, 0.67, 0},
+	"out":    {0.33, 1, 0.68, 1},
+	"inout":  {0.65, 0, 0.35, 1},
+}
+
+// revealBatches groups shape indexes so that each batch improves the score
+// by at least scoreDelta, the same way Frames groups shapes into frames.
+func (model *Model) revealBatches(scoreDelta float64) [][]int {
+	var result [][]int
+	var batch []int
+	previous := 10.0
+	for i, score := range model.Scores {
+		batch = append(batch, i)
+		if previous-score >= scoreDelta {
+			previous = score
+			result = append(result, batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		result = append(result, batch)
+	}
+	return result
+}
+
+// AnimatedSVG returns an SVG in which the shapes appear in the order they
+// were added, one batch after another.
+func (model *Model) AnimatedSVG(opt SVGAnimation) string {
+	batches := model.revealBatches(opt.ScoreDelta)
+	curve, ok := easingCurves[opt.Easing]
+	if !ok {
+		curve = easingCurves["linear"]
+	}
+	fade := opt.Fade
+	if opt.Pop {
+		fade = 0
+	}
+	var lines []string
+	lines = append(lines, model.svgHeader())
+	if opt.CSS {
+		timing := fmt.Sprintf("cubic-bezier(%g,%g,%g,%g)", curve[0], curve[1], curve[2], curve[3])
+		lines = append(lines, fmt.Sprintf("<style>@keyframes reveal{from{opacity:0}to{opacity:1}} .reveal{animation:reveal %gs %s both}</style>", fade, timing))
+	}
+	lines = append(lines, model.backgroundSVG()...)
+	lines = append(lines, fmt.Sprintf("<g transform=\"scale(%f) translate(0.5 0.5)\">", model.Scale))
+	for b, batch := range batches {
+		begin := opt.Duration * float64(b) / float64(len(batches))
+		if opt.CSS {
+			lines = append(lines, fmt.Sprintf("<g class=\"reveal\" style=\"animation-delay:%.3fs\">", begin))
+		} else {
+			lines = append(lines, "<g opacity=\"0\">")
+			if fade == 0 {
+				lines = append(lines, fmt.Sprintf("<set attributeName=\"opacity\" to=\"1\" begin=\"%.3fs\" fill=\"freeze\" />", begin))
+			} else {
+				lines = append(lines, fmt.Sprintf("<animate attributeName=\"opacity\" from=\"0\" to=\"1\" begin=\"%.3fs\" dur=\"%gs\" calcMode=\"spline\" keyTimes=\"0;1\" keySplines=\"%g %g %g %g\" fill=\"freeze\" />",
+					begin, fade, curve[0], curve[1], curve[2], curve[3]))
+			}
+		}
+		for _, i := range batch {
+			lines = append(lines, model.shapeSVG(i))
+		}
+		lines = append(lines, "</g>")
+	}
+	lines = append(lines, "</g>")
+	lines = append(lines, "</svg>")
+	return strings.Join(lines, "\n")
+}
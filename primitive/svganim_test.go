@@ -0,0 +1,99 @@
+package primitive
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRevealBatches(t *testing.T) {
+	model := &Model{Scores: []float64{0.5, 0.4, 0.38, 0.3, 0.2}}
+	batches := model.revealBatches(0.05)
+	want := [][]int{{0}, {1}, {2, 3}, {4}}
+	if len(batches) != len(want) {
+		t.Fatalf("got %v, want %v", batches, want)
+	}
+	for i := range want {
+		if len(batches[i]) != len(want[i]) || batches[i][0] != want[i][0] {
+			t.Fatalf("got %v, want %v", batches, want)
+		}
+	}
+}
+
+func TestAnimatedSVG(t *testing.T) {
+	model := animationModel(t)
+	for _, opt := range []SVGAnimation{
+		{Duration: 2, Fade: 0.5, Easing: "out"},
+		{Duration: 2, Pop: true},
+		{CSS: true, Duration: 2, Fade: 0.5, Easing: "inout"},
+	} {
+		svg := model.AnimatedSVG(opt)
+		counts := map[string]int{}
+		d := xml.NewDecoder(strings.NewReader(svg))
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("invalid svg: %v", err)
+			}
+			if e, ok := tok.(xml.StartElement); ok {
+				counts[e.Name.Local]++
+			}
+		}
+		animations := counts["animate"] + counts["set"]
+		if opt.CSS {
+			animations = strings.Count(svg, "class=\"reveal\"")
+		}
+		if animations != len(model.Shapes) {
+			t.Errorf("%+v: got %d animations, want %d", opt, animations, len(model.Shapes))
+		}
+	}
+}
+
+func TestSVGMetadata(t *testing.T) {
+	model := animationModel(t)
+	deltas := model.ScoreDeltas()
+	if len(deltas) != len(model.Shapes) {
+		t.Fatalf("got %d deltas, want %d", len(deltas), len(model.Shapes))
+	}
+	svg := model.SVGWithOptions(SVGOptions{Metadata: true, SortByImportance: true})
+	var indexes []int
+	previous := 10.0
+	d := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid svg: %v", err)
+		}
+		e, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, a := range e.Attr {
+			if a.Name.Local == "data-index" {
+				i, _ := strconv.Atoi(a.Value)
+				indexes = append(indexes, i)
+				if deltas[i] > previous {
+					t.Errorf("shape %d is out of order", i)
+				}
+				previous = deltas[i]
+			}
+			if a.Name.Local == "data-type" && a.Value != "triangle" {
+				t.Errorf("got data-type %s, want triangle", a.Value)
+			}
+		}
+	}
+	if len(indexes) != len(model.Shapes) {
+		t.Errorf("got %d data-index attributes, want %d", len(indexes), len(model.Shapes))
+	}
+	if model.SVGWithOptions(SVGOptions{}) != model.SVG() {
+		t.Errorf("SVG with no options differs from SVG")
+	}
+}
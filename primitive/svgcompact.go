@@ -0,0 +1,192 @@
+package primitive
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CompactSVG controls the output of Model.CompactSVG.
+type CompactSVG struct {
+	Precision int     // decimal places kept in coordinates
+	Merge     bool    // merge runs of same colored shapes into one path
+	ViewBox   bool    // add a viewBox so the image scales to any size
+	Blur      float64 // gaussian blur standard deviation in output pixels
+}
+
+// pathShape is implemented by shapes that can be written as path data in
+// model coordinates.
+type pathShape interface {
+	svgPath(precision int) string
+}
+
+// formatNumber rounds x to precision decimal places and drops redundant
+// zeros, so 0.50 is written as .5 and 3.0 as 3.
+func formatNumber(x float64, precision int) string {
+	s := strconv.FormatFloat(x, 'f', precision, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	if strings.HasPrefix(s, "0.") {
+		return s[1:]
+	}
+	if strings.HasPrefix(s, "-0.") {
+		return "-" + s[2:]
+	}
+	return s
+}
+
+func formatNumbers(precision int, values ...float64) string {
+	s := make([]string, len(values))
+	for i, x := range values {
+		s[i] = formatNumber(x, precision)
+	}
+	return strings.Join(s, " ")
+}
+
+// shortHex returns a hex color, using the three digit form when possible.
+func shortHex(c Color) string {
+	if c.R%17 == 0 && c.G%17 == 0 && c.B%17 == 0 {
+		return fmt.Sprintf("#%x%x%x", c.R/17, c.G/17, c.B/17)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// CompactSVG returns the same drawing as SVG, but smaller. Coordinates are
+// rounded to opt.Precision decimal places, runs of shapes with the same
+// color share one <g> with the fill attributes, and with opt.Merge, shapes
+// in a run are merged into a single <path> as long as they don't overlap,
+// so overlapping translucent shapes still composite the same way.
+func (model *Model) CompactSVG(opt CompactSVG) string {
+	var lines []string
+	header := model.svgHeader()
+	if opt.ViewBox {
+		header = strings.Replace(header, ">", fmt.Sprintf(" viewBox=\"0 0 %d %d\">", model.Sw, model.Sh), 1)
+	}
+	lines = append(lines, header)
+	if opt.Blur > 0 {
+		// the alpha transfer keeps the blurred edges of the image opaque
+		lines = append(lines, fmt.Sprintf("<filter id=\"b\" x=\"0\" y=\"0\" width=\"100%%\" height=\"100%%\"><feGaussianBlur stdDeviation=\"%s\" edgeMode=\"duplicate\"/><feComponentTransfer><feFuncA type=\"discrete\" tableValues=\"1 1\"/></feComponentTransfer></filter>",
+			formatNumber(opt.Blur, 2)))
+		lines = append(lines, "<g filter=\"url(#b)\">")
+	}
+	bg := model.outputColor(model.Background)
+	if model.Gradient == nil && model.BaseImage == nil && bg.A == 255 {
+		lines = append(lines, fmt.Sprintf("<rect width=\"%d\" height=\"%d\" fill=\"%s\"/>", model.Sw, model.Sh, shortHex(bg)))
+	} else {
+		lines = append(lines, model.backgroundSVG()...)
+	}
+	lines = append(lines, fmt.Sprintf("<g transform=\"scale(%s) translate(.5 .5)\">",
+		strconv.FormatFloat(model.Scale, 'g', -1, 64)))
+	style := ""
+	if model.Blend != BlendNormal {
+		style = fmt.Sprintf(" style=\"mix-blend-mode:%s\"", model.Blend.CSS())
+	}
+	for i := 0; i < len(model.Shapes); {
+		// find the run of shapes with the same color and kind of paint
+		c := model.outputColor(model.Colors[i])
+		_, stroke := model.Shapes[i].(*Quadratic)
+		j := i + 1
+		for j < len(model.Shapes) && model.outputColor(model.Colors[j]) == c {
+			if _, ok := model.Shapes[j].(*Quadratic); ok != stroke {
+				break
+			}
+			j++
+		}
+		paint := "fill"
+		if stroke {
+			paint = "stroke"
+		}
+		attrs := fmt.Sprintf("%s=\"%s\"", paint, shortHex(c))
+		if c.A < 255 {
+			attrs += fmt.Sprintf(" %s-opacity=\"%s\"", paint, formatNumber(float64(c.A)/255, 3))
+		}
+		if stroke {
+			attrs = "fill=\"none\" " + attrs
+		}
+		if j-i > 1 {
+			lines = append(lines, "<g "+attrs+">")
+			attrs = ""
+		} else {
+			attrs += " "
+		}
+		var merged []string
+		var bounds []image.Rectangle
+		flush := func() {
+			if len(merged) > 0 {
+				lines = append(lines, fmt.Sprintf("<path %sd=\"%s\"%s/>", attrs, strings.Join(merged, ""), style))
+				merged, bounds = nil, nil
+			}
+		}
+		for k := i; k < j; k++ {
+			shape := model.Shapes[k]
+			ps, ok := shape.(pathShape)
+			if !ok {
+				flush()
+				lines = append(lines, shape.SVG(strings.TrimSpace(attrs)+style))
+				continue
+			}
+			if q, ok := shape.(*Quadratic); ok {
+				flush()
+				lines = append(lines, fmt.Sprintf("<path %sd=\"%s\" stroke-width=\"%s\"%s/>",
+					attrs, q.svgPath(opt.Precision), formatNumber(q.Width, opt.Precision), style))
+				continue
+			}
+			if !opt.Merge {
+				lines = append(lines, fmt.Sprintf("<path %sd=\"%s\"%s/>", attrs, ps.svgPath(opt.Precision), style))
+				continue
+			}
+			// pad by a pixel for antialiasing at the edges
+			r := scanlineBounds(shape.Rasterize()).Inset(-1)
+			for _, b := range bounds {
+				if b.Overlaps(r) {
+					flush()
+					break
+				}
+			}
+			merged = append(merged, ps.svgPath(opt.Precision))
+			bounds = append(bounds, r)
+		}
+		flush()
+		if j-i > 1 {
+			lines = append(lines, "</g>")
+		}
+		i = j
+	}
+	lines = append(lines, "</g>")
+	if opt.Blur > 0 {
+		lines = append(lines, "</g>")
+	}
+	lines = append(lines, "</svg>")
+	return strings.Join(lines, "\n")
+}
+
+// SVGDataURI returns svg as a URL encoded data URI for <img src> or CSS
+// url(). Only the characters that need it are escaped, which is much
+// shorter than base64.
+func SVGDataURI(svg string) string {
+	svg = strings.Replace(svg, "\n", "", -1)
+	svg = strings.Replace(svg, "\"", "'", -1)
+	svg = strings.NewReplacer("%", "%25", "#", "%23", "<", "%3C", ">", "%3E", "{", "%7B", "}", "%7D").Replace(svg)
+	return "data:image/svg+xml," + svg
+}
+
+// ellipsePath writes an ellipse as two arcs between the ends of its x axis.
+func ellipsePath(x, y, rx, ry, angle float64, precision int) string {
+	a := radians(angle)
+	dx, dy := rx*math.Cos(a), rx*math.Sin(a)
+	r := formatNumbers(precision, rx, ry, angle)
+	return fmt.Sprintf("M%sA%s 1 1 %sA%s 1 1 %sZ",
+		formatNumbers(precision, x-dx, y-dy),
+		r, formatNumbers(precision, x+dx, y+dy),
+		r, formatNumbers(precision, x-dx, y-dy))
+}
+
+func polygonPath(precision int, points ...float64) string {
+	return fmt.Sprintf("M%sZ", formatNumbers(precision, points...))
+}
@@ -0,0 +1,89 @@
+package primitive
+
+import (
+	"encoding/xml"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		x         float64
+		precision int
+		want      string
+	}{
+		{3, 1, "3"},
+		{0.5, 1, ".5"},
+		{-0.26, 1, "-.3"},
+		{-0.01, 1, "0"},
+		{12.345, 2, "12.35"},
+		{1.5, 0, "2"},
+	}
+	for _, test := range tests {
+		if got := formatNumber(test.x, test.precision); got != test.want {
+			t.Errorf("formatNumber(%v, %d) = %q, want %q", test.x, test.precision, got, test.want)
+		}
+	}
+}
+
+func TestShortHex(t *testing.T) {
+	if got := shortHex(Color{0xaa, 0xbb, 0x00, 255}); got != "#ab0" {
+		t.Errorf("got %s, want #ab0", got)
+	}
+	if got := shortHex(Color{0xab, 0xbb, 0x00, 255}); got != "#abbb00" {
+		t.Errorf("got %s, want #abbb00", got)
+	}
+}
+
+func TestCompactSVG(t *testing.T) {
+	model := animationModel(t)
+	full := model.SVG()
+	for _, opt := range []CompactSVG{{Precision: 1}, {Precision: 0, Merge: true}} {
+		svg := model.CompactSVG(opt)
+		if len(svg) >= len(full) {
+			t.Errorf("%+v: compact svg is %d bytes, full svg is %d", opt, len(svg), len(full))
+		}
+		d := xml.NewDecoder(strings.NewReader(svg))
+		for {
+			_, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%+v: invalid svg: %v", opt, err)
+			}
+		}
+	}
+}
+
+func TestCompactSVGMerge(t *testing.T) {
+	w := NewWorker(uniformRGBA(image.Rect(0, 0, 64, 64), color.Black))
+	c := Color{255, 0, 0, 128}
+	model := &Model{Sw: 64, Sh: 64, Scale: 1,
+		Shapes: []Shape{
+			&Rectangle{w, 0, 0, 9, 9},
+			&Rectangle{w, 20, 20, 29, 29},
+			&Rectangle{w, 25, 25, 34, 34},
+		},
+		Colors: []Color{c, c, c},
+	}
+	svg := model.CompactSVG(CompactSVG{Precision: 1, Merge: true})
+	// the first two are merged, the overlapping third is not
+	if n := strings.Count(svg, "<path"); n != 2 {
+		t.Errorf("got %d paths, want 2:\n%s", n, svg)
+	}
+	if !strings.Contains(svg, "d=\"M0 0h10v10h-10ZM20 20h10v10h-10Z\"") {
+		t.Errorf("rectangles not merged:\n%s", svg)
+	}
+}
+
+func TestSVGDataURI(t *testing.T) {
+	got := SVGDataURI("<svg width=\"100%\">\n<rect fill=\"#abc\"/>\n</svg>")
+	want := "data:image/svg+xml,%3Csvg width='100%25'%3E%3Crect fill='%23abc'/%3E%3C/svg%3E"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
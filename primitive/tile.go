@@ -0,0 +1,260 @@
+package primitive
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/raster"
+)
+
+// Tile is one independently optimized region of a TiledModel. Its model
+// targets the owned region plus an overlap margin, so shapes near the edge
+// of the region are scored against the neighboring content they will be
+// seen next to, but only the owned region is kept in the output.
+type Tile struct {
+	Model  *Model
+	X, Y   int
+	Region image.Rectangle
+}
+
+// TiledModel partitions a large target into tiles that are optimized in
+// parallel and merged when rendering.
+type TiledModel struct {
+	Sw, Sh     int
+	Scale      float64
+	Background Color
+	Target     *image.RGBA
+	Tiles      []*Tile
+	Score      float64
+	NumWorkers int
+}
+
+// NewTiledModel splits target into a tilesX by tilesY grid. Each tile scores
+// shapes against its region plus overlap pixels of margin, where after every
+// step it sees the shapes its neighbors have committed, so shapes near the
+// edges continue the neighboring content instead of fighting it. Shapes are
+// still clipped at tile edges; a larger overlap hides the seams better.
+func NewTiledModel(target image.Image, background Color, size, tilesX, tilesY, overlap, numWorkers int) *TiledModel {
+	rgba := imageToRGBA(target)
+	w := rgba.Bounds().Size().X
+	h := rgba.Bounds().Size().Y
+	sw, sh, scale := outputSize(w, h, size)
+
+	tm := &TiledModel{}
+	tm.Sw = sw
+	tm.Sh = sh
+	tm.Scale = scale
+	tm.Background = background
+	tm.Target = rgba
+	tm.NumWorkers = numWorkers
+	for j := 0; j < tilesY; j++ {
+		for i := 0; i < tilesX; i++ {
+			region := image.Rect(i*w/tilesX, j*h/tilesY, (i+1)*w/tilesX, (j+1)*h/tilesY)
+			crop := image.Rect(
+				region.Min.X-overlap, region.Min.Y-overlap,
+				region.Max.X+overlap, region.Max.Y+overlap).Intersect(rgba.Bounds())
+			sub := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+			draw.Draw(sub, sub.Rect, rgba, crop.Min, draw.Src)
+			// tile models are only rendered through the tiled model, so
+			// keep their own output contexts small
+			size := maxInt(crop.Dx(), crop.Dy())
+			model := NewModel(sub, background, size, 1)
+			tm.Tiles = append(tm.Tiles, &Tile{model, crop.Min.X, crop.Min.Y, region})
+		}
+	}
+	tm.Score = tm.score()
+	return tm
+}
+
+// Seed seeds every tile's model, offsetting the seed by the tile index.
+func (tm *TiledModel) Seed(seed int64) {
+	for i, tile := range tm.Tiles {
+		tile.Model.Seed(seed + int64(i*len(tile.Model.Workers)))
+	}
+}
+
+// Step adds one shape to every tile, running up to NumWorkers tiles at once.
+func (tm *TiledModel) Step(shapeType ShapeType, alpha, repeat int) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan bool, tm.NumWorkers)
+	counter := 0
+	for _, tile := range tm.Tiles {
+		wg.Add(1)
+		sem <- true
+		go func(tile *Tile) {
+			n := tile.Model.Step(shapeType, alpha, repeat)
+			mu.Lock()
+			counter += n
+			mu.Unlock()
+			<-sem
+			wg.Done()
+		}(tile)
+	}
+	wg.Wait()
+	tm.syncMargins()
+	tm.Score = tm.score()
+	return counter
+}
+
+// syncMargins copies each tile's owned region into the overlap margins of
+// its neighbors and rescores them, so the next step of every tile is scored
+// against the output its neighbors have committed rather than its own
+// shapes spilling past its region.
+func (tm *TiledModel) syncMargins() {
+	for _, tile := range tm.Tiles {
+		model := tile.Model
+		origin := image.Pt(tile.X, tile.Y)
+		crop := model.Current.Rect.Add(origin)
+		for _, other := range tm.Tiles {
+			r := other.Region.Intersect(crop)
+			if other == tile || r.Empty() {
+				continue
+			}
+			sp := r.Min.Sub(image.Pt(other.X, other.Y))
+			draw.Draw(model.Current, r.Sub(origin), other.Model.Current, sp, draw.Src)
+		}
+		model.Score = differenceFull(model.Target, model.Current)
+	}
+}
+
+// score computes the overall error using only the region owned by each tile.
+func (tm *TiledModel) score() float64 {
+	var total float64
+	for _, tile := range tm.Tiles {
+		model := tile.Model
+		r := tile.Region.Sub(image.Pt(tile.X, tile.Y))
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := model.Target.PixOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				for j := 0; j < 4; j++ {
+					d := float64(model.Target.Pix[i+j]) - float64(model.Current.Pix[i+j])
+					total += d * d
+				}
+				i += 4
+			}
+		}
+	}
+	size := tm.Target.Bounds().Size()
+	return math.Sqrt(total/float64(size.X*size.Y*4)) / 255
+}
+
+// PaletteCounts returns the number of shapes drawn with each palette color
+// across all tiles.
+func (tm *TiledModel) PaletteCounts() []int {
+	var result []int
+	for _, tile := range tm.Tiles {
+		counts := tile.Model.PaletteCounts()
+		if result == nil {
+			result = counts
+			continue
+		}
+		for i, n := range counts {
+			result[i] += n
+		}
+	}
+	return result
+}
+
+// background returns the output background color, which every tile maps
+// the same way.
+func (tm *TiledModel) background() Color {
+	model := tm.Tiles[0].Model
+	return model.outputColor(model.Background)
+}
+
+func (tm *TiledModel) Image() image.Image {
+	if tm.Tiles[0].Model.Renderer == RendererNative {
+		return tm.imageNative()
+	}
+	dc := gg.NewContext(tm.Sw, tm.Sh)
+	bg := tm.background()
+	dc.SetColor(bg.NRGBA())
+	dc.Clear()
+	for _, tile := range tm.Tiles {
+		model := tile.Model
+		r := tile.Region
+		dc.Push()
+		dc.DrawRectangle(
+			float64(r.Min.X)*tm.Scale, float64(r.Min.Y)*tm.Scale,
+			float64(r.Dx())*tm.Scale, float64(r.Dy())*tm.Scale)
+		dc.Clip()
+		dc.Scale(tm.Scale, tm.Scale)
+		dc.Translate(float64(tile.X)+0.5, float64(tile.Y)+0.5)
+		for i, shape := range model.Shapes {
+			c := model.outputColor(model.Colors[i])
+			dc.SetRGBA255(c.R, c.G, c.B, c.A)
+			shape.Draw(dc, tm.Scale)
+		}
+		dc.ResetClip()
+		dc.Pop()
+	}
+	return dc.Image()
+}
+
+func (tm *TiledModel) SVG() string {
+	bg := tm.background()
+	var lines []string
+	lines = append(lines, fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\" width=\"%d\" height=\"%d\">", tm.Sw, tm.Sh))
+	if bg.A > 0 {
+		lines = append(lines, fmt.Sprintf("<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#%02x%02x%02x\" fill-opacity=\"%f\" />", tm.Sw, tm.Sh, bg.R, bg.G, bg.B, float64(bg.A)/255))
+	}
+	lines = append(lines, "<defs>")
+	for i, tile := range tm.Tiles {
+		r := tile.Region
+		lines = append(lines, fmt.Sprintf("<clipPath id=\"tile%d\"><rect x=\"%f\" y=\"%f\" width=\"%f\" height=\"%f\" /></clipPath>", i,
+			float64(r.Min.X)*tm.Scale, float64(r.Min.Y)*tm.Scale,
+			float64(r.Dx())*tm.Scale, float64(r.Dy())*tm.Scale))
+	}
+	lines = append(lines, "</defs>")
+	for i, tile := range tm.Tiles {
+		lines = append(lines, fmt.Sprintf("<g clip-path=\"url(#tile%d)\">", i))
+		lines = append(lines, fmt.Sprintf("<g transform=\"scale(%f) translate(%f %f)\">", tm.Scale, float64(tile.X)+0.5, float64(tile.Y)+0.5))
+		for j := range tile.Model.Shapes {
+			lines = append(lines, tile.Model.shapeSVG(j))
+		}
+		lines = append(lines, "</g>")
+		lines = append(lines, "</g>")
+	}
+	lines = append(lines, "</svg>")
+	return strings.Join(lines, "\n")
+}
+
+// imageNative draws each tile's shapes scaled to the output size with the
+// scanline rasterizer, as Model does for RendererNative, keeping only the
+// lines inside the tile's region.
+func (tm *TiledModel) imageNative() image.Image {
+	bg := tm.background()
+	im := uniformRGBA(image.Rect(0, 0, tm.Sw, tm.Sh), bg.NRGBA())
+	scale := func(v int) int {
+		return int(math.Round(float64(v) * tm.Scale))
+	}
+	var lines []Scanline
+	for _, tile := range tm.Tiles {
+		model := tile.Model
+		size := model.Target.Bounds().Size()
+		w, h := scale(size.X), scale(size.Y)
+		worker := &Worker{W: w, H: h, Rasterizer: raster.NewRasterizer(w, h)}
+		dx, dy := scale(tile.X), scale(tile.Y)
+		r := tile.Region
+		clip := image.Rect(scale(r.Min.X), scale(r.Min.Y), scale(r.Max.X), scale(r.Max.Y)).Intersect(im.Rect)
+		for i, shape := range model.Shapes {
+			lines = lines[:0]
+			for _, line := range shape.Scaled(worker, tm.Scale).Rasterize() {
+				line.Y += dy
+				line.X1 = maxInt(line.X1+dx, clip.Min.X)
+				line.X2 = minInt(line.X2+dx, clip.Max.X-1)
+				if line.Y >= clip.Min.Y && line.Y < clip.Max.Y && line.X1 <= line.X2 {
+					lines = append(lines, line)
+				}
+			}
+			drawLinesBlend(im, model.outputColor(model.Colors[i]), lines, model.Blend)
+		}
+	}
+	return im
+}
@@ -0,0 +1,38 @@
+package primitive
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+func TestTiledMarginsMatchNeighbors(t *testing.T) {
+	im, err := LoadImage("../examples/lenna.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im = resize.Thumbnail(48, 48, im, resize.Bilinear)
+	tm := NewTiledModel(im, MakeColor(AverageImageColor(im)), 48, 2, 2, 6, 1)
+	tm.Seed(1)
+	for i := 0; i < 5; i++ {
+		tm.Step(ShapeTypeEllipse, 128, 0)
+	}
+	// every tile, margin included, sees the merged output
+	out := tm.Image().(*image.RGBA)
+	for i, tile := range tm.Tiles {
+		model := tile.Model
+		r := model.Current.Rect
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if got, want := model.Current.RGBAAt(x, y), out.RGBAAt(x+tile.X, y+tile.Y); got != want {
+					t.Fatalf("tile %d pixel %d,%d is %v, want %v", i, x, y, got, want)
+				}
+			}
+		}
+		if want := differenceFull(model.Target, model.Current); math.Abs(model.Score-want) > 1e-9 {
+			t.Errorf("tile %d score is %f, want %f", i, model.Score, want)
+		}
+	}
+}
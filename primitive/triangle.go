@@ -8,7 +8,7 @@ import (
 )
 
 type Triangle struct {
-	Worker *Worker
+	Worker *Worker `json:"-"`
 	X1, Y1 int
 	X2, Y2 int
 	X3, Y3 int
@@ -41,11 +41,28 @@ func (t *Triangle) SVG(attrs string) string {
 		attrs, t.X1, t.Y1, t.X2, t.Y2, t.X3, t.Y3)
 }
 
+func (t *Triangle) svgPath(precision int) string {
+	return polygonPath(precision,
+		float64(t.X1), float64(t.Y1), float64(t.X2), float64(t.Y2), float64(t.X3), float64(t.Y3))
+}
+
+func (t *Triangle) Path(p PathWriter) {
+	writePolygon(p,
+		float64(t.X1), float64(t.Y1), float64(t.X2), float64(t.Y2), float64(t.X3), float64(t.Y3))
+}
+
 func (t *Triangle) Copy() Shape {
 	a := *t
 	return &a
 }
 
+func (t *Triangle) Scaled(worker *Worker, scale float64) Shape {
+	return &Triangle{worker,
+		scaleInt(t.X1, scale), scaleInt(t.Y1, scale),
+		scaleInt(t.X2, scale), scaleInt(t.Y2, scale),
+		scaleInt(t.X3, scale), scaleInt(t.Y3, scale)}
+}
+
 func (t *Triangle) Mutate() {
 	w := t.Worker.W
 	h := t.Worker.H
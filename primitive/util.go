@@ -1,6 +1,7 @@
 package primitive
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
@@ -14,21 +15,31 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 func LoadImage(path string) (image.Image, error) {
+	var data []byte
+	var err error
 	if path == "-" {
-		im, _, err := image.Decode(os.Stdin)
-		return im, err
+		data, err = ioutil.ReadAll(os.Stdin)
 	} else {
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, err
-		}
-		defer file.Close()
-		im, _, err := image.Decode(file)
-		return im, err
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	im, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
 	}
+	if AutoOrient && format == "jpeg" {
+		im = orient(im, exifOrientation(data))
+	}
+	return im, nil
 }
 
 func SaveFile(path, contents string) error {
@@ -64,6 +75,24 @@ func SaveJPG(path string, im image.Image, quality int) error {
 	return jpeg.Encode(file, im, &jpeg.Options{quality})
 }
 
+func SaveTIFF(path string, im image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return tiff.Encode(file, im, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+}
+
+func SaveBMP(path string, im image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return bmp.Encode(file, im)
+}
+
 func SaveGIF(path string, frames []image.Image, delay, lastDelay int) error {
 	g := gif.GIF{}
 	for i, src := range frames {
@@ -147,6 +176,13 @@ func clampInt(x, lo, hi int) int {
 	return x
 }
 
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
 func minInt(a, b int) int {
 	if a < b {
 		return a
@@ -185,21 +221,66 @@ func uniformRGBA(r image.Rectangle, c color.Color) *image.RGBA {
 	return im
 }
 
-func AverageImageColor(im image.Image) color.NRGBA {
-	rgba := imageToRGBA(im)
-	size := rgba.Bounds().Size()
-	w, h := size.X, size.Y
-	var r, g, b int
+// downsampleRGBA averages factor x factor blocks of pixels. Partial blocks
+// at the right and bottom edges average the pixels they contain.
+func downsampleRGBA(src *image.RGBA, factor int) *image.RGBA {
+	size := src.Bounds().Size()
+	w := (size.X + factor - 1) / factor
+	h := (size.Y + factor - 1) / factor
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
 	for y := 0; y < h; y++ {
 		for x := 0; x < w; x++ {
-			c := rgba.RGBAAt(x, y)
-			r += int(c.R)
-			g += int(c.G)
-			b += int(c.B)
+			var sum [4]int
+			n := 0
+			for sy := y * factor; sy < minInt((y+1)*factor, size.Y); sy++ {
+				i := src.PixOffset(x*factor, sy)
+				for sx := x * factor; sx < minInt((x+1)*factor, size.X); sx++ {
+					for j := 0; j < 4; j++ {
+						sum[j] += int(src.Pix[i+j])
+					}
+					n++
+					i += 4
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for j := 0; j < 4; j++ {
+				dst.Pix[i+j] = uint8((sum[j] + n/2) / n)
+			}
 		}
 	}
-	r /= w * h
-	g /= w * h
-	b /= w * h
+	return dst
+}
+
+// asRGBA returns im if it is already an *image.RGBA whose pixels start at
+// the origin with no padding, and a copy at the origin otherwise, so
+// callers can loop over Pix and use image coordinates from 0.
+func asRGBA(im image.Image) *image.RGBA {
+	if rgba, ok := im.(*image.RGBA); ok && rgba.Rect.Min == image.ZP && rgba.Stride == 4*rgba.Rect.Dx() {
+		return rgba
+	}
+	b := im.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Rect, im, b.Min, draw.Src)
+	return dst
+}
+
+// AverageImageColor returns the mean color of the visible pixels of im,
+// weighting each pixel by its alpha.
+func AverageImageColor(im image.Image) color.NRGBA {
+	rgba := asRGBA(im)
+	var r, g, b, a int
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		r += int(rgba.Pix[i+0])
+		g += int(rgba.Pix[i+1])
+		b += int(rgba.Pix[i+2])
+		a += int(rgba.Pix[i+3])
+	}
+	if a == 0 {
+		return color.NRGBA{0, 0, 0, 255}
+	}
+	// premultiplied sums divided by total alpha give the weighted mean
+	r = (r*255 + a/2) / a
+	g = (g*255 + a/2) / a
+	b = (b*255 + a/2) / a
 	return color.NRGBA{uint8(r), uint8(g), uint8(b), 255}
 }
@@ -0,0 +1,132 @@
+package primitive
+
+import "math"
+
+// PathWriter receives the outline of a shape. Shapes write their geometry
+// to it so that vector formats other than SVG don't need to know about each
+// kind of shape.
+type PathWriter interface {
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	CubicTo(x1, y1, x2, y2, x3, y3 float64)
+	ClosePath()
+}
+
+// strokeWidth returns the line width of shapes that are stroked instead of
+// filled, in model coordinates.
+func strokeWidth(shape Shape) (float64, bool) {
+	if q, ok := shape.(*Quadratic); ok {
+		return q.Width, true
+	}
+	return 0, false
+}
+
+// transformPath applies f to every point written to p.
+type transformPath struct {
+	p PathWriter
+	f func(x, y float64) (float64, float64)
+}
+
+func (t *transformPath) MoveTo(x, y float64) {
+	t.p.MoveTo(t.f(x, y))
+}
+
+func (t *transformPath) LineTo(x, y float64) {
+	t.p.LineTo(t.f(x, y))
+}
+
+func (t *transformPath) CubicTo(x1, y1, x2, y2, x3, y3 float64) {
+	x1, y1 = t.f(x1, y1)
+	x2, y2 = t.f(x2, y2)
+	x3, y3 = t.f(x3, y3)
+	t.p.CubicTo(x1, y1, x2, y2, x3, y3)
+}
+
+func (t *transformPath) ClosePath() {
+	t.p.ClosePath()
+}
+
+// outputPath maps model coordinates to output pixels the same way as the
+// SVG output's transform. With flip, y points up from the bottom edge, as
+// in PDF, PostScript and plotter coordinates.
+func (model *Model) outputPath(p PathWriter, flip bool) PathWriter {
+	scale, h := model.Scale, float64(model.Sh)
+	return &transformPath{p, func(x, y float64) (float64, float64) {
+		x, y = (x+0.5)*scale, (y+0.5)*scale
+		if flip {
+			y = h - y
+		}
+		return x, y
+	}}
+}
+
+// writeEllipse writes an ellipse rotated by angle degrees as four cubic
+// bezier arcs.
+func writeEllipse(p PathWriter, x, y, rx, ry, angle float64) {
+	const k = 0.5522847498 // 4/3 * (sqrt(2) - 1)
+	a := radians(angle)
+	c, s := math.Cos(a), math.Sin(a)
+	pt := func(u, v float64) (float64, float64) {
+		u, v = u*rx, v*ry
+		return x + u*c - v*s, y + u*s + v*c
+	}
+	p.MoveTo(pt(1, 0))
+	arcs := [][6]float64{
+		{1, k, k, 1, 0, 1},
+		{-k, 1, -1, k, -1, 0},
+		{-1, -k, -k, -1, 0, -1},
+		{k, -1, 1, -k, 1, 0},
+	}
+	for _, arc := range arcs {
+		x1, y1 := pt(arc[0], arc[1])
+		x2, y2 := pt(arc[2], arc[3])
+		x3, y3 := pt(arc[4], arc[5])
+		p.CubicTo(x1, y1, x2, y2, x3, y3)
+	}
+	p.ClosePath()
+}
+
+func writePolygon(p PathWriter, points ...float64) {
+	p.MoveTo(points[0], points[1])
+	for i := 2; i+1 < len(points); i += 2 {
+		p.LineTo(points[i], points[i+1])
+	}
+	p.ClosePath()
+}
+
+// polylines flattens paths into lists of points, for writers that can
+// only draw straight lines.
+type polylines struct {
+	lines  [][]float64
+	closed []bool
+}
+
+func (p *polylines) last() []float64 {
+	return p.lines[len(p.lines)-1]
+}
+
+func (p *polylines) MoveTo(x, y float64) {
+	p.lines = append(p.lines, []float64{x, y})
+	p.closed = append(p.closed, false)
+}
+
+func (p *polylines) LineTo(x, y float64) {
+	p.lines[len(p.lines)-1] = append(p.last(), x, y)
+}
+
+func (p *polylines) CubicTo(x1, y1, x2, y2, x3, y3 float64) {
+	line := p.last()
+	x0, y0 := line[len(line)-2], line[len(line)-1]
+	length := math.Hypot(x1-x0, y1-y0) + math.Hypot(x2-x1, y2-y1) + math.Hypot(x3-x2, y3-y2)
+	n := clampInt(int(length/2), 4, 64)
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		p.LineTo(a*x0+b*x1+c*x2+d*x3, a*y0+b*y1+c*y2+d*y3)
+	}
+}
+
+func (p *polylines) ClosePath() {
+	p.closed[len(p.closed)-1] = true
+}
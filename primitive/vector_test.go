@@ -0,0 +1,85 @@
+package primitive
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func TestPathMatchesDraw(t *testing.T) {
+	const w, h = 100, 80
+	worker := NewWorker(image.NewRGBA(image.Rect(0, 0, w, h)))
+	worker.Rnd = rand.New(rand.NewSource(1))
+	for _, st := range kernelShapeTypes {
+		var area, diff float64
+		for i := 0; i < 50; i++ {
+			shape := worker.RandomState(st, 255).Shape
+			a := gg.NewContext(w, h)
+			shape.Draw(a, 1)
+			b := gg.NewContext(w, h)
+			shape.Path(b)
+			if width, ok := strokeWidth(shape); ok {
+				b.SetLineWidth(width)
+				b.Stroke()
+			} else {
+				b.Fill()
+			}
+			pa := a.Image().(*image.RGBA).Pix
+			pb := b.Image().(*image.RGBA).Pix
+			for j := 3; j < len(pa); j += 4 {
+				area += float64(pa[j]) / 255
+				diff += math.Abs(float64(pa[j])-float64(pb[j])) / 255
+			}
+		}
+		// thin strokes are flattened at different points than gg's curves
+		if diff/area > 0.05 {
+			t.Errorf("shape type %d: path differs from drawn shape by %.1f%%", st, diff/area*100)
+		}
+	}
+}
+
+func TestHatch(t *testing.T) {
+	square := [][]float64{{0, 0, 10, 0, 10, 10, 0, 10}}
+	lines := hatch(square, 1, 0)
+	if len(lines) != 10 {
+		t.Fatalf("got %d hatch lines, want 10", len(lines))
+	}
+	for _, line := range lines {
+		if length := math.Abs(line[2] - line[0]); math.Abs(length-10) > 1e-9 {
+			t.Errorf("hatch line %v has length %v, want 10", line, length)
+		}
+	}
+	if n := len(hatch(square, 1, 45)); n != 14 {
+		t.Errorf("got %d diagonal hatch lines, want 14", n)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	model := animationModel(t)
+	data := model.PDF()
+	// every xref entry must point at its object
+	i := bytes.LastIndex(data, []byte("\nxref\n"))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(data[i:], -1)
+	if len(entries) < 4 {
+		t.Fatalf("got %d xref entries", len(entries))
+	}
+	for j, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		want := fmt.Sprintf("%d 0 obj", j+1)
+		if !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("xref entry %d does not point at %q", j+1, want)
+		}
+	}
+	eps := model.EPS()
+	if n := strings.Count(eps, "\nfill\n"); n != len(model.Shapes) {
+		t.Errorf("eps has %d fills, want %d", n, len(model.Shapes))
+	}
+}
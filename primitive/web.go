@@ -0,0 +1,159 @@
+package primitive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// cssColor returns a hex color when c is opaque, or rgba() otherwise.
+func cssColor(c Color) string {
+	if c.A == 255 {
+		return shortHex(c)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%s)", c.R, c.G, c.B, formatNumber(float64(c.A)/255, 3))
+}
+
+// canvasPath writes paths as canvas 2D context calls on c.
+type canvasPath struct {
+	buf *bytes.Buffer
+}
+
+func (p *canvasPath) MoveTo(x, y float64) {
+	fmt.Fprintf(p.buf, "c.moveTo(%s);", formatNumbersComma(x, y))
+}
+
+func (p *canvasPath) LineTo(x, y float64) {
+	fmt.Fprintf(p.buf, "c.lineTo(%s);", formatNumbersComma(x, y))
+}
+
+func (p *canvasPath) CubicTo(x1, y1, x2, y2, x3, y3 float64) {
+	fmt.Fprintf(p.buf, "c.bezierCurveTo(%s);", formatNumbersComma(x1, y1, x2, y2, x3, y3))
+}
+
+func (p *canvasPath) ClosePath() {
+	p.buf.WriteString("c.closePath();")
+}
+
+func formatNumbersComma(values ...float64) string {
+	return strings.Replace(formatNumbers(2, values...), " ", ",", -1)
+}
+
+// canvasBlendModes maps blend modes to globalCompositeOperation values.
+var canvasBlendModes = map[BlendMode]string{
+	BlendMultiply:   "multiply",
+	BlendScreen:     "screen",
+	BlendAdd:        "lighter",
+	BlendDifference: "difference",
+}
+
+// Canvas returns a self-contained HTML page that draws the result on a
+// <canvas> with a script, using the same transform as SVG.
+func (model *Model) Canvas() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<canvas id=\"primitive\" width=\"%d\" height=\"%d\"></canvas>\n<script>\n", model.Sw, model.Sh)
+	buf.WriteString("var c=document.getElementById('primitive').getContext('2d');\n")
+	bg := model.outputColor(model.Background)
+	if model.BaseImage != nil {
+		// draw the shapes once the base image has loaded
+		fmt.Fprintf(&buf, "var b=new Image();b.onload=function(){c.drawImage(b,0,0,%d,%d);\n", model.Sw, model.Sh)
+	} else if model.Gradient != nil {
+		g := model.Gradient.Map(model.outputColor)
+		s := model.Scale
+		fmt.Fprintf(&buf, "var g=c.createLinearGradient(%s);g.addColorStop(0,'%s');g.addColorStop(1,'%s');c.fillStyle=g;c.fillRect(0,0,%d,%d);\n",
+			formatNumbersComma(g.X1*s, g.Y1*s, g.X2*s, g.Y2*s), cssColor(g.Color1), cssColor(g.Color2), model.Sw, model.Sh)
+	} else if bg.A > 0 {
+		fmt.Fprintf(&buf, "c.fillStyle='%s';c.fillRect(0,0,%d,%d);\n", cssColor(bg), model.Sw, model.Sh)
+	}
+	fmt.Fprintf(&buf, "c.scale(%s,%s);c.translate(.5,.5);\n", formatNumber(model.Scale, 6), formatNumber(model.Scale, 6))
+	if op, ok := canvasBlendModes[model.Blend]; ok {
+		fmt.Fprintf(&buf, "c.globalCompositeOperation='%s';\n", op)
+	}
+	path := &canvasPath{&buf}
+	for i, shape := range model.Shapes {
+		c := cssColor(model.outputColor(model.Colors[i]))
+		buf.WriteString("c.beginPath();")
+		shape.Path(path)
+		if w, ok := strokeWidth(shape); ok {
+			fmt.Fprintf(&buf, "c.strokeStyle='%s';c.lineWidth=%s;c.stroke();\n", c, formatNumber(w, 2))
+		} else {
+			fmt.Fprintf(&buf, "c.fillStyle='%s';c.fill();\n", c)
+		}
+	}
+	if model.BaseImage != nil {
+		fmt.Fprintf(&buf, "};b.src='%s';\n", model.baseURL())
+	}
+	buf.WriteString("</script>\n")
+	return buf.String()
+}
+
+var errCSSShape = errors.New("css output only supports rectangles, ellipses and circles")
+
+// CSS returns a stylesheet with a .primitive class whose background layers
+// draw the result, for rectangles, ellipses and circles. Rectangles are
+// sized solid gradients and ellipses are radial gradients with a hard edge.
+func (model *Model) CSS() (string, error) {
+	s := model.Scale
+	px := func(values ...float64) string {
+		var a []string
+		for _, v := range values {
+			if v == 0 {
+				a = append(a, "0")
+			} else {
+				a = append(a, formatNumber(v, 2)+"px")
+			}
+		}
+		return strings.Join(a, " ")
+	}
+	var layers []string
+	for i := len(model.Shapes) - 1; i >= 0; i-- {
+		c := cssColor(model.outputColor(model.Colors[i]))
+		switch shape := model.Shapes[i].(type) {
+		case *Rectangle:
+			x1, y1, x2, y2 := shape.bounds()
+			layers = append(layers, fmt.Sprintf("linear-gradient(%s,%s) %s/%s no-repeat", c, c,
+				px((float64(x1)+0.5)*s, (float64(y1)+0.5)*s), px(float64(x2-x1+1)*s, float64(y2-y1+1)*s)))
+		case *Ellipse:
+			layers = append(layers, fmt.Sprintf("radial-gradient(%s at %s,%s 99%%,transparent 100%%)",
+				px(float64(shape.Rx)*s, float64(shape.Ry)*s),
+				px((float64(shape.X)+0.5)*s, (float64(shape.Y)+0.5)*s), c))
+		default:
+			return "", errCSSShape
+		}
+	}
+	bg := model.outputColor(model.Background)
+	if model.BaseImage != nil {
+		layers = append(layers, fmt.Sprintf("url(%s) 0 0/%s no-repeat", model.baseURL(), px(float64(model.Sw), float64(model.Sh))))
+	} else if model.Gradient != nil {
+		layers = append(layers, model.cssGradient())
+	} else if bg.A > 0 {
+		layers = append(layers, fmt.Sprintf("linear-gradient(%s,%s)", cssColor(bg), cssColor(bg)))
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, ".primitive{width:%dpx;height:%dpx;", model.Sw, model.Sh)
+	if model.Blend != BlendNormal {
+		fmt.Fprintf(&buf, "background-blend-mode:%s;", model.Blend.CSS())
+	}
+	fmt.Fprintf(&buf, "background:\n%s}\n", strings.Join(layers, ",\n"))
+	return buf.String(), nil
+}
+
+// cssGradient places the gradient's end colors along the line that CSS
+// uses for a linear-gradient at the same angle.
+func (model *Model) cssGradient() string {
+	g := model.Gradient.Map(model.outputColor)
+	s := model.Scale
+	w, h := float64(model.Sw), float64(model.Sh)
+	x1, y1, x2, y2 := g.X1*s, g.Y1*s, g.X2*s, g.Y2*s
+	// 0deg points up and angles go clockwise
+	a := math.Atan2(x2-x1, y1-y2)
+	dx, dy := math.Sin(a), -math.Cos(a)
+	length := math.Abs(w*dx) + math.Abs(h*dy)
+	sx, sy := w/2-dx*length/2, h/2-dy*length/2
+	p1 := (x1-sx)*dx + (y1-sy)*dy
+	p2 := (x2-sx)*dx + (y2-sy)*dy
+	return fmt.Sprintf("linear-gradient(%sdeg,%s %spx,%s %spx)", formatNumber(degrees(a), 2),
+		cssColor(g.Color1), formatNumber(p1, 2), cssColor(g.Color2), formatNumber(p2, 2))
+}
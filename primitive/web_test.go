@@ -0,0 +1,55 @@
+package primitive
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestCSS(t *testing.T) {
+	w := NewWorker(uniformRGBA(image.Rect(0, 0, 64, 32), color.Black))
+	model := &Model{Sw: 128, Sh: 64, Scale: 2,
+		Background: Color{255, 255, 255, 255},
+		Shapes: []Shape{
+			&Rectangle{w, 0, 0, 9, 4},
+			&Ellipse{w, 20, 10, 5, 3, false},
+		},
+		Colors: []Color{{255, 0, 0, 255}, {0, 0, 255, 128}},
+	}
+	css, err := model.CSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"radial-gradient(10px 6px at 41px 21px,rgba(0,0,255,.502) 99%,transparent 100%)",
+		"linear-gradient(#f00,#f00) 1px 1px/20px 10px no-repeat",
+		"linear-gradient(#fff,#fff)}",
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("css does not contain %q:\n%s", want, css)
+		}
+	}
+	// the ellipse was added last, so it is the top layer
+	if strings.Index(css, "radial") > strings.Index(css, "#f00") {
+		t.Errorf("layers are not in reverse order:\n%s", css)
+	}
+	model.Shapes = append(model.Shapes, &Triangle{w, 0, 0, 1, 1, 0, 1})
+	model.Colors = append(model.Colors, Color{0, 0, 0, 255})
+	if _, err := model.CSS(); err != errCSSShape {
+		t.Errorf("got error %v, want %v", err, errCSSShape)
+	}
+	if n := strings.Count(model.Canvas(), "c.beginPath()"); n != 3 {
+		t.Errorf("canvas draws %d shapes, want 3", n)
+	}
+}
+
+func TestCSSGradient(t *testing.T) {
+	model := &Model{Sw: 100, Sh: 50, Scale: 2,
+		Gradient: &Gradient{10, 5, 40, 5, Color{0, 0, 0, 255}, Color{255, 255, 255, 255}},
+	}
+	want := "linear-gradient(90deg,#000 20px,#fff 80px)"
+	if got := model.cssGradient(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
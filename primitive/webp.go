@@ -0,0 +1,355 @@
+package primitive
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+)
+
+// This is a minimal lossless WebP (VP8L) encoder. It uses the subtract
+// green transform and backward references to the left and above pixels,
+// which is enough for the large flat regions in primitive's output. There
+// is no pure Go lossy WebP encoder, so lossy output is not supported.
+
+const (
+	webpMaxLength    = 4096
+	webpLengthCodes  = 24
+	webpDistanceCode = 40
+)
+
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+func SaveWebP(path string, im image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return EncodeWebP(file, im)
+}
+
+// webpMaxSize is the largest width and height VP8L can store.
+const webpMaxSize = 16384
+
+func EncodeWebP(w io.Writer, im image.Image) error {
+	b := im.Bounds()
+	if b.Dx() > webpMaxSize || b.Dy() > webpMaxSize {
+		return fmt.Errorf("webp images can't be larger than %dx%d, got %dx%d", webpMaxSize, webpMaxSize, b.Dx(), b.Dy())
+	}
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, im, b.Min, draw.Src)
+	data := encodeVP8L(nrgba)
+	size := len(data)
+	padded := size + size%2
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(size))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if padded > size {
+		data = append(data, 0)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc uint
+}
+
+func (bw *bitWriter) write(value uint32, n uint) {
+	bw.acc |= uint64(value) << bw.nacc
+	bw.nacc += n
+	for bw.nacc >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nacc -= 8
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	if bw.nacc > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nacc = 0, 0
+	}
+	return bw.buf
+}
+
+// webpToken is either a literal pixel or a backward reference.
+type webpToken struct {
+	argb   [4]int
+	length int
+	dist   int
+}
+
+// webpPrefix splits a length or distance into a prefix symbol and extra
+// bits as described in the VP8L specification.
+func webpPrefix(n int) (symbol, nbits, extra int) {
+	v := n - 1
+	if v < 4 {
+		return v, 0, 0
+	}
+	h := bits.Len(uint(v)) - 1
+	second := (v >> uint(h-1)) & 1
+	return 2*h + second, h - 1, v & (1<<uint(h-1) - 1)
+}
+
+func encodeVP8L(im *image.NRGBA) []byte {
+	w, h := im.Rect.Dx(), im.Rect.Dy()
+	n := w * h
+	pix := make([]uint32, n)
+	alpha := false
+	for i := range pix {
+		p := im.Pix[i*4 : i*4+4]
+		pix[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		alpha = alpha || p[3] != 255
+	}
+
+	// find backward references
+	var tokens []webpToken
+	for p := 0; p < n; {
+		length, dist := 0, 0
+		if p > 0 {
+			l := 0
+			for l < webpMaxLength && p+l < n && pix[p+l] == pix[p+l-1] {
+				l++
+			}
+			length, dist = l, 2
+		}
+		if p >= w {
+			l := 0
+			for l < webpMaxLength && p+l < n && pix[p+l] == pix[p+l-w] {
+				l++
+			}
+			if l > length {
+				length, dist = l, 1
+			}
+		}
+		if length >= 3 {
+			tokens = append(tokens, webpToken{length: length, dist: dist})
+			p += length
+			continue
+		}
+		c := pix[p]
+		g := int(c>>8) & 0xff
+		// subtract green transform
+		r := (int(c>>16) - g) & 0xff
+		b := (int(c) - g) & 0xff
+		tokens = append(tokens, webpToken{argb: [4]int{int(c >> 24), r, g, b}})
+		p++
+	}
+
+	// build histograms
+	green := make([]int, 256+webpLengthCodes)
+	red := make([]int, 256)
+	blue := make([]int, 256)
+	alphas := make([]int, 256)
+	dists := make([]int, webpDistanceCode)
+	for _, t := range tokens {
+		if t.length > 0 {
+			s, _, _ := webpPrefix(t.length)
+			green[256+s]++
+			s, _, _ = webpPrefix(t.dist)
+			dists[s]++
+		} else {
+			alphas[t.argb[0]]++
+			red[t.argb[1]]++
+			green[t.argb[2]]++
+			blue[t.argb[3]]++
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+	// one subtract green transform
+	bw.write(1, 1)
+	bw.write(2, 2)
+	bw.write(0, 1)
+	// no color cache and a single set of prefix codes
+	bw.write(0, 1)
+	bw.write(0, 1)
+	gc := writePrefixCode(bw, green)
+	rc := writePrefixCode(bw, red)
+	bc := writePrefixCode(bw, blue)
+	ac := writePrefixCode(bw, alphas)
+	dc := writePrefixCode(bw, dists)
+
+	for _, t := range tokens {
+		if t.length > 0 {
+			s, nbits, extra := webpPrefix(t.length)
+			gc.write(bw, 256+s)
+			bw.write(uint32(extra), uint(nbits))
+			s, nbits, extra = webpPrefix(t.dist)
+			dc.write(bw, s)
+			bw.write(uint32(extra), uint(nbits))
+		} else {
+			gc.write(bw, t.argb[2])
+			rc.write(bw, t.argb[1])
+			bc.write(bw, t.argb[3])
+			ac.write(bw, t.argb[0])
+		}
+	}
+	return bw.bytes()
+}
+
+// prefixCode holds bit reversed canonical Huffman codes, ready to be written
+// least significant bit first.
+type prefixCode struct {
+	codes   []uint32
+	lengths []int
+}
+
+func (pc *prefixCode) write(bw *bitWriter, symbol int) {
+	bw.write(pc.codes[symbol], uint(pc.lengths[symbol]))
+}
+
+func writePrefixCode(bw *bitWriter, freqs []int) *prefixCode {
+	var used []int
+	for s, f := range freqs {
+		if f > 0 {
+			used = append(used, s)
+		}
+	}
+	pc := &prefixCode{make([]uint32, len(freqs)), make([]int, len(freqs))}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		// simple code: one symbol takes no bits, two symbols take one bit
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		bw.write(1, 1)
+		bw.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(used[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.write(uint32(used[1]), 8)
+			pc.codes[used[1]] = 1
+			pc.lengths[used[0]] = 1
+			pc.lengths[used[1]] = 1
+		}
+		return pc
+	}
+
+	lengths := huffmanLengths(freqs, 15)
+	clFreqs := make([]int, 19)
+	for _, l := range lengths {
+		clFreqs[l]++
+	}
+	clLengths := huffmanLengths(clFreqs, 7)
+	cl := newPrefixCode(clLengths)
+	bw.write(0, 1)
+	bw.write(uint32(len(webpCodeLengthOrder)-4), 4)
+	for _, s := range webpCodeLengthOrder {
+		bw.write(uint32(clLengths[s]), 3)
+	}
+	// code lengths are given for the whole alphabet
+	bw.write(0, 1)
+	for _, l := range lengths {
+		cl.write(bw, l)
+	}
+	return newPrefixCode(lengths)
+}
+
+// newPrefixCode assigns canonical codes. A code with a single symbol is
+// decoded without reading any bits, so nothing is written for it.
+func newPrefixCode(lengths []int) *prefixCode {
+	pc := &prefixCode{make([]uint32, len(lengths)), make([]int, len(lengths))}
+	var counts [16]int
+	used := 0
+	for _, l := range lengths {
+		if l > 0 {
+			counts[l]++
+			used++
+		}
+	}
+	var next [16]uint32
+	code := uint32(0)
+	for l := 1; l < 16; l++ {
+		code = (code + uint32(counts[l-1])) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		if used == 1 {
+			break
+		}
+		pc.codes[s] = bits.Reverse32(next[l]) >> uint(32-l)
+		pc.lengths[s] = l
+		next[l]++
+	}
+	return pc
+}
+
+// huffmanLengths returns Huffman code lengths no longer than limit. Longer
+// codes are avoided by flattening the frequencies until the tree fits.
+func huffmanLengths(freqs []int, limit int) []int {
+	type node struct {
+		freq    int
+		symbols []int
+	}
+	f := make([]int, len(freqs))
+	copy(f, freqs)
+	for {
+		lengths := make([]int, len(f))
+		var nodes []node
+		for s, x := range f {
+			if x > 0 {
+				nodes = append(nodes, node{x, []int{s}})
+			}
+		}
+		if len(nodes) == 1 {
+			lengths[nodes[0].symbols[0]] = 1
+			return lengths
+		}
+		for len(nodes) > 1 {
+			sort.SliceStable(nodes, func(i, j int) bool {
+				return nodes[i].freq < nodes[j].freq
+			})
+			a, b := nodes[0], nodes[1]
+			symbols := make([]int, 0, len(a.symbols)+len(b.symbols))
+			symbols = append(symbols, a.symbols...)
+			symbols = append(symbols, b.symbols...)
+			for _, s := range symbols {
+				lengths[s]++
+			}
+			nodes = append(nodes[2:], node{a.freq + b.freq, symbols})
+		}
+		max := 0
+		for _, l := range lengths {
+			max = maxInt(max, l)
+		}
+		if max <= limit {
+			return lengths
+		}
+		for i, x := range f {
+			if x > 0 {
+				f[i] = x>>1 | 1
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package primitive
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	noise := image.NewNRGBA(image.Rect(0, 0, 37, 23))
+	rnd.Read(noise.Pix)
+	flat := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	draw.Draw(flat, flat.Rect, image.NewUniform(color.NRGBA{200, 100, 50, 255}), image.ZP, draw.Src)
+	draw.Draw(flat, image.Rect(20, 30, 120, 90), image.NewUniform(color.NRGBA{10, 20, 30, 255}), image.ZP, draw.Src)
+	single := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	for _, im := range []*image.NRGBA{noise, flat, single} {
+		var buf bytes.Buffer
+		if err := EncodeWebP(&buf, im); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := webp.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%v: %v", im.Rect, err)
+		}
+		got := image.NewNRGBA(im.Rect)
+		draw.Draw(got, got.Rect, decoded, image.ZP, draw.Src)
+		if !bytes.Equal(got.Pix, im.Pix) {
+			t.Errorf("%v: decoded pixels differ", im.Rect)
+		}
+	}
+}
+
+func TestEncodeWebPMaxSize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, image.NewNRGBA(image.Rect(0, 0, 16384, 1))); err != nil {
+		t.Fatal(err)
+	}
+	if config, err := webp.DecodeConfig(&buf); err != nil || config.Width != 16384 {
+		t.Errorf("got %v, %v, want a width of 16384", config, err)
+	}
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 16385, 1), image.Rect(0, 0, 1, 16385)} {
+		buf.Reset()
+		if err := EncodeWebP(&buf, image.NewNRGBA(r)); err == nil {
+			t.Errorf("%v: no error for an image larger than webp allows", r)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%v: wrote %d bytes", r, buf.Len())
+		}
+	}
+}
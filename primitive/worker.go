@@ -9,16 +9,23 @@ import (
 )
 
 type Worker struct {
-	W, H       int
-	Target     *image.RGBA
-	Current    *image.RGBA
-	Buffer     *image.RGBA
-	Rasterizer *raster.Rasterizer
-	Lines      []Scanline
-	Heatmap    *Heatmap
-	Rnd        *rand.Rand
-	Score      float64
-	Counter    int
+	W, H        int
+	Target      *image.RGBA
+	Current     *image.RGBA
+	Buffer      *image.RGBA
+	TargetSums  *rowSums
+	CurrentSums *rowSums
+	Rasterizer  *raster.Rasterizer
+	Lines       []Scanline
+	Heatmap     *Heatmap
+	Rnd         *rand.Rand
+	Score       float64
+	Counter     int
+	// Coarse is an optional downsampled worker used to search for shapes
+	// cheaply before refining the best one at full resolution.
+	Coarse      *Worker
+	CoarseScale float64
+	colorConfig
 }
 
 func NewWorker(target *image.RGBA) *Worker {
@@ -29,6 +36,7 @@ func NewWorker(target *image.RGBA) *Worker {
 	worker.H = h
 	worker.Target = target
 	worker.Buffer = image.NewRGBA(target.Bounds())
+	worker.TargetSums = newRowSums(target)
 	worker.Rasterizer = raster.NewRasterizer(w, h)
 	worker.Lines = make([]Scanline, 0, 4096) // TODO: based on height
 	worker.Heatmap = NewHeatmap(w, h)
@@ -41,19 +49,50 @@ func (worker *Worker) Init(current *image.RGBA, score float64) {
 	worker.Score = score
 	worker.Counter = 0
 	worker.Heatmap.Clear()
+	if worker.fused() {
+		if worker.CurrentSums == nil {
+			worker.CurrentSums = newRowSums(current)
+		} else {
+			worker.CurrentSums.update(current)
+		}
+	}
+}
+
+// fused reports whether Energy can use the single pass kernel. Grayscale
+// images score the same with it because their channels are all equal,
+// once SetGrayscale has rebuilt TargetSums from the grayed target.
+func (worker *Worker) fused() bool {
+	return worker.Blend == BlendNormal && len(worker.Palette) == 0 && worker.Source == nil
 }
 
 func (worker *Worker) Energy(shape Shape, alpha int) float64 {
 	worker.Counter++
 	lines := shape.Rasterize()
 	// worker.Heatmap.Add(lines)
-	color := computeColor(worker.Target, worker.Current, lines, alpha)
+	if worker.fused() {
+		if alpha == 0 {
+			alpha = worker.optimalAlpha(worker.Target, worker.Current, lines)
+		}
+		color := computeColorSums(worker.TargetSums, worker.CurrentSums, lines, alpha)
+		return energyNormal(worker.Target, worker.Current, color, lines, worker.Score)
+	}
+	color := worker.computeColor(worker.Target, worker.Current, worker.Buffer, lines, alpha, worker.Score)
 	copyLines(worker.Buffer, worker.Current, lines)
-	drawLines(worker.Buffer, color, lines)
-	return differencePartial(worker.Target, worker.Current, worker.Buffer, worker.Score, lines)
+	worker.drawLines(worker.Buffer, color, lines)
+	return worker.difference(worker.Target, worker.Current, worker.Buffer, worker.Score, lines)
 }
 
 func (worker *Worker) BestHillClimbState(t ShapeType, a, n, age, m int) *State {
+	if worker.Coarse != nil {
+		// search at the coarse level and refine only the winner
+		state := worker.Coarse.BestHillClimbState(t, a, n, age, m)
+		shape := state.Shape.Scaled(worker, worker.CoarseScale)
+		state = NewState(worker, shape, a)
+		before := state.Energy()
+		state = HillClimb(state, age).(*State)
+		vv("coarse: %.6f -> %dx hill climb: %.6f\n", before, age, state.Energy())
+		return state
+	}
 	var bestEnergy float64
 	var bestState *State
 	for i := 0; i < m; i++ {
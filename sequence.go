@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fogleman/primitive/primitive"
+	"github.com/nfnt/resize"
+)
+
+// sequencePaths returns the frames of an image sequence input, which is a
+// directory of images or a glob pattern, sorted by name. It returns nil for
+// a single image, even one whose name looks like a pattern.
+func sequencePaths(input string) []string {
+	var paths []string
+	info, err := os.Stat(input)
+	if err == nil && !info.IsDir() {
+		return nil
+	}
+	if err == nil {
+		entries, err := ioutil.ReadDir(input)
+		check(err)
+		for _, e := range entries {
+			if !e.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+				paths = append(paths, filepath.Join(input, e.Name()))
+			}
+		}
+	} else if strings.ContainsAny(input, "*?[") {
+		paths, err = filepath.Glob(input)
+		check(err)
+	} else {
+		return nil
+	}
+	if len(paths) == 0 {
+		check(fmt.Errorf("no images found in %s", input))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// runSequence processes an image sequence for video. The first frame is
+// made as usual and every later frame starts from the previous frame's
+// shapes, refitting them to its image so they move instead of flickering.
+// Outputs are written for every frame with the frame number in the path.
+func runSequence(paths []string, input *primitive.Model, configure func(*primitive.Model), save func(*primitive.Model, string, string)) {
+	var track primitive.Track
+	var prev *primitive.Model
+	start := time.Now()
+	size := input.Target.Bounds().Size()
+	for k, path := range paths {
+		model := input
+		if k > 0 {
+			primitive.Log(1, "reading %s\n", path)
+			im, err := primitive.LoadImage(path)
+			check(err)
+			if InputSize > 0 {
+				im = resize.Thumbnail(uint(InputSize), uint(InputSize), im, resize.Bilinear)
+			}
+			if im.Bounds().Size() != size {
+				check(fmt.Errorf("%s is %v, want the size of the first frame %v", path, im.Bounds().Size(), size))
+			}
+			model = primitive.NewModel(im, input.Background, OutputSize, Workers)
+			configure(model)
+			if Seed != 0 {
+				model.Seed(Seed + int64(k))
+			}
+		}
+		t := time.Now()
+		n := 0
+		if prev == nil {
+			for _, config := range Configs {
+				for i := 0; i < config.Count; {
+					max := Speculate
+					if max > config.Count-i {
+						max = config.Count - i
+					}
+					c, placed := model.StepSpeculative(primitive.ShapeType(config.Mode), config.Alpha, config.Repeat, max)
+					n += c
+					i += placed
+				}
+			}
+		} else {
+			n = model.Refit(prev, Age)
+		}
+		nps := primitive.NumberString(float64(n) / time.Since(t).Seconds())
+		elapsed := time.Since(start).Seconds()
+		primitive.Log(1, "frame %d: t=%.3f, score=%.6f, shapes=%d, n=%d, n/s=%s\n",
+			k+1, elapsed, model.Score, len(model.Shapes), n, nps)
+
+		for _, output := range Outputs {
+			path := fmt.Sprintf(output, k+1)
+			primitive.Log(1, "writing %s\n", path)
+			save(model, path, strings.ToLower(filepath.Ext(output)))
+		}
+		if Track != "" {
+			check(track.Add(model))
+		}
+		prev = model
+	}
+	if Track != "" {
+		primitive.Log(1, "writing %s\n", Track)
+		data, err := track.JSON()
+		check(err)
+		check(primitive.SaveFile(Track, data))
+	}
+}